@@ -0,0 +1,26 @@
+module github.com/wasmCloud/contrib/secrets/secrets-file
+
+go 1.22.5
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/nats-io/nats.go v1.36.0
+	github.com/nats-io/nkeys v0.4.7
+	github.com/wasmCloud/contrib/secrets/secrets-kubernetes v0.0.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	golang.org/x/crypto v0.25.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	golang.org/x/time v0.5.0 // indirect
+)
+
+replace github.com/wasmCloud/contrib/secrets/secrets-kubernetes => ../secrets-kubernetes
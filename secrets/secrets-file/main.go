@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+const ServiceName = "file"
+
+type fileSecretsServer struct {
+	store *fileStore
+}
+
+type fileApplicationPolicy struct {
+	secrets.EntityRule
+	secrets.HostRule
+}
+
+func parseApplicationPolicy(r *secrets.Request) (*fileApplicationPolicy, error) {
+	policy := &fileApplicationPolicy{}
+	if r.Context.Application == nil || r.Context.Application.Policy == "" {
+		return policy, nil
+	}
+
+	rawPolicy, err := r.Context.Application.PolicyProperties()
+	if err != nil {
+		return nil, err
+	}
+	if len(rawPolicy) == 0 {
+		return policy, nil
+	}
+
+	err = json.Unmarshal(rawPolicy, policy)
+	return policy, err
+}
+
+func (s *fileSecretsServer) Get(_ context.Context, r *secrets.Request) (*secrets.SecretValue, error) {
+	policy, err := parseApplicationPolicy(r)
+	if err != nil {
+		return nil, secrets.ErrPolicy.With(err.Error())
+	}
+
+	if r.Key == "" {
+		return nil, secrets.ErrOther.With("missing secret name")
+	}
+
+	if r.Field == "" {
+		return nil, secrets.ErrOther.With("missing secret key/field")
+	}
+
+	if err := r.Context.AuthorizeHost(policy.HostRule); err != nil {
+		return nil, err
+	}
+
+	if err := r.Context.AuthorizeEntity(policy.EntityRule); err != nil {
+		return nil, err
+	}
+
+	value, version, ok := s.store.Lookup(r.Key, r.Field)
+	if !ok {
+		return nil, secrets.ErrSecretNotFound
+	}
+
+	// only the current contents are kept around
+	if r.Version != "" && r.Version != version {
+		return nil, secrets.ErrVersionNotFound
+	}
+
+	if !utf8.Valid(value) {
+		return &secrets.SecretValue{
+			BinarySecret: secrets.ByteArray(value),
+			Version:      version,
+		}, nil
+	}
+
+	return &secrets.SecretValue{
+		StringSecret: string(value),
+		Version:      version,
+	}, nil
+}
+
+func main() {
+	var (
+		natsURL            = flag.String("nats-url", "nats://127.0.0.1:4222", "NATS Server URL")
+		natsCreds          = flag.String("nats-creds", "", "NATS Credentials File")
+		secretsBackendSeed = flag.String("backend-seed", "", "NKeys Curve Seed. Leave blank for ephemeral key, only recommended for development use")
+		backendSeedFile    = flag.String("backend-seed-file", "", "File with the NKeys Curve Seed")
+		secretsDir         = flag.String("dir", "", "Directory to serve secrets from, as '<key>/<field>' files")
+		serviceName        = flag.String("service-name", ServiceName, "Backend name, part of the secrets subject")
+	)
+	flag.Parse()
+
+	if *secretsDir == "" {
+		slog.Error("Missing --dir")
+		os.Exit(1)
+	}
+
+	store, err := newFileStore(*secretsDir)
+	if err != nil {
+		slog.Error("Couldn't load secrets", slog.Any("error", err))
+		os.Exit(1)
+	}
+	slog.Info("Loaded secrets", slog.String("dir", *secretsDir), slog.Int("secrets", store.Len()))
+
+	natsConnectOps := []nats.Option{}
+	if *natsCreds != "" {
+		natsConnectOps = append(natsConnectOps, nats.UserCredentials(*natsCreds))
+	}
+
+	nc, err := nats.Connect(*natsURL, natsConnectOps...)
+	if err != nil {
+		slog.Error("Couldn't setup nats client", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	seed := *secretsBackendSeed
+	if *backendSeedFile != "" {
+		data, err := os.ReadFile(*backendSeedFile)
+		if err != nil {
+			slog.Error("Couldn't read seed file", slog.Any("error", err))
+			os.Exit(1)
+		}
+		seed = strings.TrimSpace(string(data))
+	}
+
+	var secretsBackendKey nkeys.KeyPair
+	if seed != "" {
+		secretsBackendKey, err = nkeys.FromCurveSeed([]byte(seed))
+	} else {
+		slog.Info("Creating ephemeral curve keys. DO NOT USE THIS IN PRODUCTION.")
+		secretsBackendKey, err = nkeys.CreateCurveKeys()
+	}
+	if err != nil {
+		slog.Error("Couldn't setup XKey", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	errorCallback := func(_ *nats.Msg, err error) {
+		slog.Error("server error", slog.Any("error", err))
+	}
+
+	secretsServer, err := secrets.NewServer(*serviceName, nc, &fileSecretsServer{store: store},
+		secrets.WithKeyPair(secretsBackendKey),
+		secrets.WithErrorCallback(errorCallback),
+	)
+	if err != nil {
+		slog.Error("Couldn't setup secrets server", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	mainCtx, mainCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
+	defer mainCancel()
+
+	if err := store.Watch(mainCtx.Done()); err != nil {
+		slog.Error("Couldn't watch secrets", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := secretsServer.Run(); err != nil {
+		slog.Error("Couldn't setup secrets protocol server", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		<-mainCtx.Done()
+		slog.Info("Signal received. Draining...")
+		if err := secretsServer.Shutdown(true); err != nil {
+			slog.Error("Couldn't drain all messages", slog.Any("error", err))
+		} else {
+			slog.Info("Drained all messages")
+		}
+		wg.Done()
+	}()
+
+	slog.Info("Server is up", slog.String("service", *serviceName))
+	wg.Wait()
+}
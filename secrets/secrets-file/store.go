@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups bursts of filesystem events, like a Kubernetes volume update, into one reload.
+const reloadDebounce = 100 * time.Millisecond
+
+// fileSecret is a secret directory: one file per field.
+type fileSecret struct {
+	fields   map[string][]byte
+	versions map[string]string
+}
+
+// fileStore serves secrets from a directory tree, 'root/<key>/<field>'.
+// Keys can be nested directories. Hidden files and directories are ignored, which skips the timestamped
+// directories of Kubernetes volumes: secrets are read through the symlinks pointing into them.
+type fileStore struct {
+	sync.RWMutex
+	root    string
+	secrets map[string]*fileSecret
+}
+
+func newFileStore(root string) (*fileStore, error) {
+	s := &fileStore{root: root}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload reads the whole tree, replacing the served secrets at once.
+func (s *fileStore) Reload() error {
+	realRoot, err := filepath.EvalSymlinks(s.root)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*fileSecret)
+	if err := loadDir(loaded, realRoot, map[string]bool{realRoot: true}, s.root, ""); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.secrets = loaded
+	return nil
+}
+
+// loadDir reads the fields of 'dir', the secret 'key', then its subdirectories.
+// Symlinked directories are followed when they resolve inside 'realRoot', like 'db -> ..data/db' in Kubernetes volumes.
+// 'ancestors' holds the resolved directories being read, so symlinks to them don't loop.
+func loadDir(loaded map[string]*fileSecret, realRoot string, ancestors map[string]bool, dir string, key string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		p := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(p)
+		if err != nil {
+			// dangling symlink
+			continue
+		}
+
+		if info.IsDir() {
+			realDir, err := filepath.EvalSymlinks(p)
+			if err != nil || ancestors[realDir] || !withinDir(realRoot, realDir) {
+				continue
+			}
+
+			ancestors[realDir] = true
+			err = loadDir(loaded, realRoot, ancestors, p, path.Join(key, entry.Name()))
+			delete(ancestors, realDir)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() || key == "" {
+			// fields need a secret directory
+			continue
+		}
+
+		value, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		secret, ok := loaded[key]
+		if !ok {
+			secret = &fileSecret{fields: make(map[string][]byte), versions: make(map[string]string)}
+			loaded[key] = secret
+		}
+		secret.fields[entry.Name()] = value
+		secret.versions[entry.Name()] = contentVersion(value)
+	}
+
+	return nil
+}
+
+// withinDir reports whether 'p' is 'dir' or below it.
+func withinDir(dir string, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Lookup returns a field value and its version.
+func (s *fileStore) Lookup(key string, field string) ([]byte, string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return nil, "", false
+	}
+
+	value, ok := secret.fields[field]
+	if !ok {
+		return nil, "", false
+	}
+
+	return value, secret.versions[field], true
+}
+
+// Len returns the number of secrets served.
+func (s *fileStore) Len() int {
+	s.RLock()
+	defer s.RUnlock()
+
+	return len(s.secrets)
+}
+
+// Watch reloads the tree on filesystem changes until 'stop' is closed.
+func (s *fileStore) Watch(stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := s.watchDirs(watcher); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-stop:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					// new directories need their own watch
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						_ = s.watchDirs(watcher)
+					}
+				}
+				debounce = time.After(reloadDebounce)
+			case <-debounce:
+				debounce = nil
+				if err := s.Reload(); err != nil {
+					slog.Error("Couldn't reload secrets", slog.String("dir", s.root), slog.Any("error", err))
+					continue
+				}
+				slog.Info("Reloaded secrets", slog.String("dir", s.root), slog.Int("secrets", s.Len()))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("Couldn't watch secrets", slog.String("dir", s.root), slog.Any("error", err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchDirs adds every directory of the tree, hidden ones included, to the watcher.
+func (s *fileStore) watchDirs(watcher *fsnotify.Watcher) error {
+	return filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return watcher.Add(p)
+	})
+}
+
+// contentVersion identifies a field value by its contents.
+func contentVersion(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:8])
+}
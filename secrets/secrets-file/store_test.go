@@ -0,0 +1,285 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFileForTest(t *testing.T, root string, name string, value string) {
+	t.Helper()
+
+	p := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(value), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func storeForTest(t *testing.T, files map[string]string) (*fileStore, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, value := range files {
+		writeFileForTest(t, root, name, value)
+	}
+
+	store, err := newFileStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, root
+}
+
+func TestFileStoreLookup(t *testing.T) {
+	store, _ := storeForTest(t, map[string]string{
+		"app/password":        "hunter2",
+		"app/username":        "admin",
+		"team/db/password":    "nested",
+		"toplevel":            "ignored",
+		"app/.hidden":         "ignored",
+		".data/app/password":  "ignored",
+		"..2024_01_01/secret": "ignored",
+	})
+
+	if want, got := 2, store.Len(); want != got {
+		t.Errorf("want %v secrets, got %v", want, got)
+	}
+
+	tests := map[string]struct {
+		key   string
+		field string
+		want  string
+		found bool
+	}{
+		"field": {
+			key:   "app",
+			field: "password",
+			want:  "hunter2",
+			found: true,
+		},
+		"nestedKey": {
+			key:   "team/db",
+			field: "password",
+			want:  "nested",
+			found: true,
+		},
+		"missingKey": {
+			key:   "missing",
+			field: "password",
+		},
+		"missingField": {
+			key:   "app",
+			field: "token",
+		},
+		"hiddenField": {
+			key:   "app",
+			field: ".hidden",
+		},
+		"rootFile": {
+			key:   "",
+			field: "toplevel",
+		},
+		"hiddenDir": {
+			key:   ".data/app",
+			field: "password",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			value, version, ok := store.Lookup(test.key, test.field)
+			if ok != test.found {
+				t.Fatalf("want found %v, got %v", test.found, ok)
+			}
+			if !ok {
+				return
+			}
+
+			if want, got := test.want, string(value); want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if want, got := contentVersion(value), version; want != got {
+				t.Errorf("want version %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestFileStoreMissingRoot(t *testing.T) {
+	if _, err := newFileStore(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	store, root := storeForTest(t, map[string]string{
+		"app/password": "hunter2",
+	})
+
+	_, oldVersion, _ := store.Lookup("app", "password")
+
+	writeFileForTest(t, root, "app/password", "correct horse")
+	writeFileForTest(t, root, "other/token", "abc")
+
+	// nothing changes until reloaded
+	if value, _, _ := store.Lookup("app", "password"); string(value) != "hunter2" {
+		t.Errorf("want %v, got %v", "hunter2", string(value))
+	}
+
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	value, version, ok := store.Lookup("app", "password")
+	if !ok || string(value) != "correct horse" {
+		t.Errorf("want %v, got %v", "correct horse", string(value))
+	}
+	if version == oldVersion {
+		t.Errorf("want a new version, got %v", version)
+	}
+	if _, _, ok := store.Lookup("other", "token"); !ok {
+		t.Error("want new secret to be loaded")
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "app")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := store.Lookup("app", "password"); ok {
+		t.Error("want removed secret to be gone")
+	}
+}
+
+func TestFileStoreWatch(t *testing.T) {
+	store, root := storeForTest(t, map[string]string{
+		"app/password": "hunter2",
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if err := store.Watch(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor := func(key string, field string, want string) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if value, _, ok := store.Lookup(key, field); ok && string(value) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("%v/%v never became %v", key, field, want)
+	}
+
+	writeFileForTest(t, root, "app/password", "correct horse")
+	waitFor("app", "password", "correct horse")
+
+	// files in new directories are picked up too
+	writeFileForTest(t, root, "new/token", "abc")
+	waitFor("new", "token", "abc")
+}
+
+func TestFileStoreWatchDebounce(t *testing.T) {
+	store, root := storeForTest(t, map[string]string{
+		"app/password": "hunter2",
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if err := store.Watch(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	// a burst of writes closer together than the debounce doesn't reload until it settles
+	for i := 0; i < 5; i++ {
+		writeFileForTest(t, root, "app/password", "write")
+		if value, _, _ := store.Lookup("app", "password"); string(value) != "hunter2" {
+			t.Fatalf("reloaded during a burst, got %v", string(value))
+		}
+		time.Sleep(reloadDebounce / 10)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, _, _ := store.Lookup("app", "password"); string(value) == "write" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("never reloaded after the burst")
+}
+
+// symlinkForTest creates 'root/name' pointing to 'target', replacing any existing link like the kubelet does.
+func symlinkForTest(t *testing.T, root string, target string, name string) {
+	t.Helper()
+
+	tmp := filepath.Join(root, name+".tmp")
+	if err := os.Symlink(target, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(root, name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileStoreAtomicWriterLayout(t *testing.T) {
+	// projected volume with 'items.path: db/password', as written by the kubelet:
+	// root/..2024_01_01/db/password, root/..data -> ..2024_01_01, root/db -> ..data/db
+	root := t.TempDir()
+	writeFileForTest(t, root, "..2024_01_01/db/password", "hunter2")
+	writeFileForTest(t, root, "..2024_01_01/app/token", "abc")
+	symlinkForTest(t, root, "..2024_01_01", "..data")
+	symlinkForTest(t, root, filepath.Join("..data", "db"), "db")
+	symlinkForTest(t, root, filepath.Join("..data", "app"), "app")
+
+	// symlinks leaving the root or looping aren't followed
+	outside := t.TempDir()
+	writeFileForTest(t, outside, "leaked/password", "outside")
+	symlinkForTest(t, root, outside, "escape")
+	symlinkForTest(t, root, ".", "loop")
+
+	store, err := newFileStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := 2, store.Len(); want != got {
+		t.Errorf("want %v secrets, got %v", want, got)
+	}
+	if value, _, ok := store.Lookup("db", "password"); !ok || string(value) != "hunter2" {
+		t.Errorf("want %v, got %v", "hunter2", string(value))
+	}
+	if value, _, ok := store.Lookup("app", "token"); !ok || string(value) != "abc" {
+		t.Errorf("want %v, got %v", "abc", string(value))
+	}
+	if _, _, ok := store.Lookup("escape/leaked", "password"); ok {
+		t.Error("symlinks leaving the root shouldn't be followed")
+	}
+
+	// updates write a new timestamped directory and swap '..data'
+	writeFileForTest(t, root, "..2024_01_02/db/password", "correct horse")
+	writeFileForTest(t, root, "..2024_01_02/app/token", "abc")
+	symlinkForTest(t, root, "..2024_01_02", "..data")
+	if err := os.RemoveAll(filepath.Join(root, "..2024_01_01")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, ok := store.Lookup("db", "password"); !ok || string(value) != "correct horse" {
+		t.Errorf("want %v, got %v", "correct horse", string(value))
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nats.go"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+const (
+	auditDecisionAllow = "allow"
+	auditDecisionDeny  = "deny"
+)
+
+// auditEvent records a single secret access decision. It never contains secret values.
+type auditEvent struct {
+	Time          time.Time       `json:"time"`
+	Operation     string          `json:"operation"`
+	HostXkey      string          `json:"host_xkey,omitempty"`
+	ComponentKey  string          `json:"component_key,omitempty"`
+	ComponentName string          `json:"component_name,omitempty"`
+	HostID        string          `json:"host_id,omitempty"`
+	Verified      bool            `json:"verified"`
+	Application   string          `json:"application,omitempty"`
+	Policy        json.RawMessage `json:"policy,omitempty"`
+	Key           string          `json:"key"`
+	Field         string          `json:"field"`
+	Version       string          `json:"version,omitempty"`
+	Namespace     string          `json:"namespace,omitempty"`
+	Impersonate   string          `json:"impersonate,omitempty"`
+	Decision      string          `json:"decision"`
+	Error         string          `json:"error,omitempty"`
+	Message       string          `json:"message,omitempty"`
+}
+
+// auditPolicy is what events record of an application policy.
+type auditPolicy struct {
+	application *secrets.ApplicationContext
+	properties  json.RawMessage
+	namespace   string
+	impersonate string
+}
+
+func newAuditPolicy(application *secrets.ApplicationContext) *auditPolicy {
+	p := &auditPolicy{application: application}
+	if properties, err := application.PolicyProperties(); err == nil {
+		p.properties = properties
+	}
+	// records where the secret is read from
+	if policy, err := parseApplicationPolicy(secrets.Context{Application: application}); err == nil {
+		p.namespace = policy.Namespace
+		p.impersonate = policy.Impersonate
+	}
+	return p
+}
+
+// newEvent describes a request. 'r' is nil when the server couldn't read the request.
+func (a *auditLog) newEvent(msg *nats.Msg, operation string, r *secrets.Request) *auditEvent {
+	event := &auditEvent{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		HostXkey:  msg.Header.Get(secrets.WasmCloudHostXkey),
+	}
+	if r == nil {
+		return event
+	}
+
+	event.Key = r.Key
+	event.Field = r.Field
+
+	if r.Context.Verified() {
+		// the claims the server verified, the JWTs aren't parsed again
+		entityCap, entityClaims, _ := r.Context.EntityCapabilities()
+		event.ComponentKey = entityCap.Subject
+		event.ComponentName = entityClaims.Name
+
+		hostCap, _, _ := r.Context.HostCapabilities()
+		event.HostID = hostCap.Subject
+		event.Verified = true
+	} else {
+		// requests denied before or by validation: record who they claim to be, unchecked
+		event.ComponentKey, event.ComponentName = claimedIdentity(r.Context.EntityJwt)
+		event.HostID, _ = claimedIdentity(r.Context.HostJwt)
+	}
+
+	if r.Context.Application != nil {
+		policy := a.policy(r.Context.Application)
+		event.Application = r.Context.Application.Name
+		event.Policy = policy.properties
+		event.Namespace = policy.namespace
+		event.Impersonate = policy.impersonate
+	}
+
+	return event
+}
+
+// claimedIdentity returns the subject and name a JWT claims, without verifying it.
+func claimedIdentity(token string) (string, string) {
+	wasCap := &secrets.WasCap{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, wasCap); err != nil {
+		return "", ""
+	}
+
+	var claims struct {
+		Name string `json:"name"`
+	}
+	// tokens without wascap claims still have a subject
+	_ = json.Unmarshal(wasCap.Was, &claims)
+
+	return wasCap.Subject, claims.Name
+}
+
+func (e *auditEvent) setResult(value *secrets.SecretValue, err *secrets.ResponseError) {
+	if err == nil {
+		e.Decision = auditDecisionAllow
+		if value != nil {
+			e.Version = value.Version
+		}
+		return
+	}
+
+	e.Decision = auditDecisionDeny
+	e.Error = err.Tip
+	e.Message = err.Message
+}
+
+type auditSink interface {
+	Write(data []byte) error
+}
+
+// auditLog fans out JSON encoded events to all configured sinks.
+type auditLog struct {
+	sinks []auditSink
+	file  *os.File
+	// batch items share their application context, their policy is parsed once
+	lastPolicy atomic.Pointer[auditPolicy]
+}
+
+// policy returns the parsed policy of 'application', reusing the last one parsed.
+func (a *auditLog) policy(application *secrets.ApplicationContext) *auditPolicy {
+	if p := a.lastPolicy.Load(); p != nil && p.application == application {
+		return p
+	}
+
+	p := newAuditPolicy(application)
+	a.lastPolicy.Store(p)
+	return p
+}
+
+// Record writes an access decision. It is a secrets.ServerAuditCallback.
+func (a *auditLog) Record(msg *nats.Msg, operation string, r *secrets.Request, value *secrets.SecretValue, err *secrets.ResponseError) {
+	event := a.newEvent(msg, operation, r)
+	event.setResult(value, err)
+
+	data, marshalErr := json.Marshal(event)
+	if marshalErr != nil {
+		slog.Error("Couldn't encode audit event", slog.Any("error", marshalErr))
+		return
+	}
+
+	for _, sink := range a.sinks {
+		if err := sink.Write(data); err != nil {
+			slog.Error("Couldn't write audit event", slog.Any("error", err))
+		}
+	}
+}
+
+// writerAuditSink writes events as JSON lines.
+type writerAuditSink struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func (s *writerAuditSink) Write(data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	_, err := s.w.Write(append(data, '\n'))
+	return err
+}
+
+// natsAuditSink publishes each event on a NATS subject.
+type natsAuditSink struct {
+	nc      *nats.Conn
+	subject string
+}
+
+func (s *natsAuditSink) Write(data []byte) error {
+	return s.nc.Publish(s.subject, data)
+}
+
+// newAuditLog creates an audit log writing to 'path' ( 'stdout' or a file, appended to ) and/or publishing to 'subject'.
+// Returns nil when neither is set.
+func newAuditLog(nc *nats.Conn, path string, subject string) (*auditLog, error) {
+	a := &auditLog{}
+
+	switch path {
+	case "":
+	case "stdout", "-":
+		a.sinks = append(a.sinks, &writerAuditSink{w: os.Stdout})
+	default:
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		a.sinks = append(a.sinks, &writerAuditSink{w: f})
+		a.file = f
+	}
+
+	if subject != "" {
+		a.sinks = append(a.sinks, &natsAuditSink{nc: nc, subject: subject})
+	}
+
+	if len(a.sinks) == 0 {
+		return nil, nil
+	}
+
+	return a, nil
+}
+
+// Close closes the audit log file, if any. Events recorded afterwards fail to be written.
+func (a *auditLog) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+// module key from the wasmcloud host codebase, nkeys can't generate 'M' keys
+const testModuleKey = "MC5CC4UD5LPDZ4C7ZNAEA4OZQ3BEFLSVQ742W3TET3ONKS4DRBVNM5IC"
+
+func signedWasCapForTest(t *testing.T, registered jwt.RegisteredClaims, claims interface{}) string {
+	t.Helper()
+
+	issuer, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered.Issuer, err = issuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := jwt.NewWithClaims(secrets.SigningMethodEd25519, &secrets.WasCap{
+		RegisteredClaims: registered,
+		Was:              rawClaims,
+		Revision:         3,
+	}).SignedString(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestAuditLogNewEvent(t *testing.T) {
+	hostKey, err := nkeys.CreateServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostID, err := hostKey.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entityJWT := signedWasCapForTest(t, jwt.RegisteredClaims{Subject: testModuleKey},
+		&secrets.ComponentClaims{Name: "component", ModuleHash: "CE90192C99C0B2C608B2E2CB619A9251FB681856C15681B1BCD62EEDA2D5128E"})
+	hostJWT := signedWasCapForTest(t, jwt.RegisteredClaims{Subject: hostID}, &secrets.HostClaims{Name: "host"})
+	expiredHostJWT := signedWasCapForTest(t, jwt.RegisteredClaims{
+		Subject:   hostID,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}, &secrets.HostClaims{Name: "host"})
+
+	tests := map[string]struct {
+		ctx      secrets.Context
+		validate bool
+		verified bool
+		identity bool
+	}{
+		"verified": {
+			ctx:      secrets.Context{EntityJwt: entityJWT, HostJwt: hostJWT},
+			validate: true,
+			verified: true,
+			identity: true,
+		},
+		"notValidated": {
+			ctx:      secrets.Context{EntityJwt: entityJWT, HostJwt: hostJWT},
+			identity: true,
+		},
+		"expired": {
+			ctx:      secrets.Context{EntityJwt: entityJWT, HostJwt: expiredHostJWT},
+			validate: true,
+			identity: true,
+		},
+		"missingJWTs": {
+			validate: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &secrets.Request{Key: "app", Field: "password", Context: test.ctx}
+			if test.validate {
+				_ = req.Context.Validate(secrets.ValidationOptions{})
+			}
+
+			event := (&auditLog{}).newEvent(nats.NewMsg("get"), "get", req)
+
+			if want, got := test.verified, event.Verified; want != got {
+				t.Errorf("want verified %v, got %v", want, got)
+			}
+
+			wantComponentKey, wantComponentName, wantHostID := "", "", ""
+			if test.identity {
+				wantComponentKey, wantComponentName, wantHostID = testModuleKey, "component", hostID
+			}
+			if want, got := wantComponentKey, event.ComponentKey; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if want, got := wantComponentName, event.ComponentName; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if want, got := wantHostID, event.HostID; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestAuditLogPolicy(t *testing.T) {
+	a := &auditLog{}
+	batch := &secrets.BatchRequest{
+		Items: []secrets.BatchItem{{Key: "a"}, {Key: "b"}},
+		Context: secrets.Context{Application: &secrets.ApplicationContext{
+			Name:   "app",
+			Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{"namespace":"team-a","impersonate":"app-reader"}}`,
+		}},
+	}
+
+	first := a.newEvent(nats.NewMsg("batch_get"), "batch_get", batch.Request(0))
+	policy := a.lastPolicy.Load()
+	second := a.newEvent(nats.NewMsg("batch_get"), "batch_get", batch.Request(1))
+
+	// batch items reuse the policy parsed for the first one
+	if a.lastPolicy.Load() != policy {
+		t.Error("policy should be parsed once per batch")
+	}
+	for _, event := range []*auditEvent{first, second} {
+		if event.Namespace != "team-a" || event.Impersonate != "app-reader" {
+			t.Errorf("want team-a/app-reader, got %v/%v", event.Namespace, event.Impersonate)
+		}
+	}
+
+	other := &secrets.ApplicationContext{Name: "other", Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{}}`}
+	event := a.newEvent(nats.NewMsg("get"), "get", &secrets.Request{Key: "a", Context: secrets.Context{Application: other}})
+	if want, got := "default", event.Namespace; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ secrets.BatchHandler = &kubeSecretsServer{}
+
+type fetchedSecret struct {
+	secret *corev1.Secret
+	err    error
+}
+
+// GetBatch serves every item like Get, reading each Kubernetes Secret once however many of its fields are requested.
+// The policy is parsed and checked once, items only check the annotations of their Secret.
+func (s *kubeSecretsServer) GetBatch(ctx context.Context, r *secrets.BatchRequest) ([]secrets.Response, error) {
+	slog.Info("GetBatch", slog.String("application", r.Context.Application.Name), slog.Int("items", len(r.Items)))
+
+	results := make([]secrets.Response, len(r.Items))
+
+	policy, err := authorizePolicy(r.Context)
+	if err != nil {
+		// items share the batch context, they are all denied
+		for i := range results {
+			results[i].Error = responseError(err)
+		}
+		return results, nil
+	}
+
+	// all items share the batch policy, so Secrets are keyed by name
+	fetched := make(map[string]fetchedSecret)
+	fetch := func(ctx context.Context, policy *kubeApplicationPolicy, name string) (*corev1.Secret, error) {
+		if f, ok := fetched[name]; ok {
+			return f.secret, f.err
+		}
+
+		kubeSecret, err := s.fetchSecret(ctx, policy, name)
+		fetched[name] = fetchedSecret{secret: kubeSecret, err: err}
+		return kubeSecret, err
+	}
+
+	for i := range r.Items {
+		value, err := s.get(ctx, r.Request(i), policy, fetch)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err != nil {
+			results[i].Error = responseError(err)
+			continue
+		}
+		results[i].Secret = value
+	}
+
+	return results, nil
+}
+
+// responseError returns 'err' as reported to hosts, errors that aren't a secrets.ResponseError are upstream errors.
+func responseError(err error) *secrets.ResponseError {
+	if respErr, ok := err.(*secrets.ResponseError); ok {
+		return respErr
+	}
+	return secrets.ErrUpstream.With(err.Error())
+}
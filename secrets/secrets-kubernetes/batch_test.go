@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nkeys"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// contextForTest returns a validated context of a component tagged 'prod' on a host labeled 'zone=a', with the policy 'properties'.
+func contextForTest(t *testing.T, properties string) secrets.Context {
+	t.Helper()
+
+	hostKey, err := nkeys.CreateServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostID, err := hostKey.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqCtx := secrets.Context{
+		Application: &secrets.ApplicationContext{
+			Name:   "app",
+			Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":` + properties + `}`,
+		},
+		EntityJwt: signedWasCapForTest(t, jwt.RegisteredClaims{Subject: testModuleKey},
+			&secrets.ComponentClaims{Name: "component", ModuleHash: "CE90192C99C0B2C608B2E2CB619A9251FB681856C15681B1BCD62EEDA2D5128E", Tags: []string{"prod"}}),
+		HostJwt: signedWasCapForTest(t, jwt.RegisteredClaims{Subject: hostID}, &secrets.HostClaims{Name: "host", Labels: map[string]string{"zone": "a"}}),
+	}
+	if err := reqCtx.Validate(secrets.ValidationOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return reqCtx
+}
+
+func TestGetBatch(t *testing.T) {
+	dev := kubeSecretForTest("dev", map[string]string{"password": "dev"})
+	dev.Annotations[AllowedTagsAnnotation] = "dev"
+
+	var gets atomic.Int32
+	client := countingClientForTest(&gets, kubeSecretForTest("app", map[string]string{"password": "hunter2", "username": "admin"}), dev)
+	s := &kubeSecretsServer{clients: clientCacheForTest(map[string]kubernetes.Interface{"": client})}
+
+	items := []secrets.BatchItem{
+		{Key: "app", Field: "password"},
+		{Key: "app", Field: "username"},
+		{Key: "dev", Field: "password"},
+	}
+
+	tests := map[string]struct {
+		properties string
+		want       []string
+		errs       []*secrets.ResponseError
+		gets       int32
+	}{
+		"annotationsPerItem": {
+			properties: `{"namespace":"default"}`,
+			want:       []string{"hunter2", "admin", ""},
+			errs:       []*secrets.ResponseError{nil, nil, secrets.ErrPolicy},
+			gets:       2,
+		},
+		"hostDenied": {
+			properties: `{"allowedHostLabels":{"zone":"b"}}`,
+			want:       []string{"", "", ""},
+			errs:       []*secrets.ResponseError{secrets.ErrPolicy, secrets.ErrPolicy, secrets.ErrPolicy},
+		},
+		"entityDenied": {
+			properties: `{"allowedTags":["staging"]}`,
+			want:       []string{"", "", ""},
+			errs:       []*secrets.ResponseError{secrets.ErrPolicy, secrets.ErrPolicy, secrets.ErrPolicy},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gets.Store(0)
+
+			results, err := s.GetBatch(context.Background(), &secrets.BatchRequest{Items: items, Context: contextForTest(t, test.properties)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, result := range results {
+				if want := test.errs[i]; (want == nil) != (result.Error == nil) || (want != nil && !errors.Is(result.Error, want)) {
+					t.Errorf("item %d: want %v, got %v", i, want, result.Error)
+					continue
+				}
+				if result.Secret != nil && result.Secret.StringSecret != test.want[i] {
+					t.Errorf("item %d: want %v, got %v", i, test.want[i], result.Secret.StringSecret)
+				}
+			}
+
+			// Secrets are read once per batch, and not at all when the policy denies it
+			if want, got := test.gets, gets.Load(); want != got {
+				t.Errorf("want %v reads, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	DefaultClientCacheSize = 64
+	DefaultClientCacheTTL  = 30 * time.Minute
+)
+
+// kubeClientCache keeps one Clientset per impersonated identity so repeated
+// requests reuse HTTP/2 connections and client-side rate limiters.
+// Entries are evicted on a least-recently-used basis once the cache is full,
+// and after sitting idle for longer than the configured TTL.
+type kubeClientCache struct {
+	sync.Mutex
+
+	baseConfig *rest.Config
+	maxSize    int
+	ttl        time.Duration
+	now        func() time.Time
+
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type kubeClientCacheEntry struct {
+	impersonate string
+	clientset   kubernetes.Interface
+	lastUsed    time.Time
+}
+
+func newKubeClientCache(baseConfig *rest.Config, maxSize int, ttl time.Duration) *kubeClientCache {
+	if maxSize <= 0 {
+		maxSize = DefaultClientCacheSize
+	}
+
+	return &kubeClientCache{
+		baseConfig: baseConfig,
+		maxSize:    maxSize,
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Get returns the Clientset for the given impersonated user, creating it if needed.
+// A blank user means the backend's own identity.
+func (c *kubeClientCache) Get(impersonate string) (kubernetes.Interface, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now()
+	c.evictExpired(now)
+
+	if elem, ok := c.entries[impersonate]; ok {
+		entry := elem.Value.(*kubeClientCacheEntry)
+		entry.lastUsed = now
+		c.lru.MoveToFront(elem)
+		return entry.clientset, nil
+	}
+
+	config := rest.CopyConfig(c.baseConfig)
+	if impersonate != "" {
+		config.Impersonate.UserName = impersonate
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	c.entries[impersonate] = c.lru.PushFront(&kubeClientCacheEntry{
+		impersonate: impersonate,
+		clientset:   clientset,
+		lastUsed:    now,
+	})
+
+	for c.lru.Len() > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+
+	return clientset, nil
+}
+
+// Len returns the number of cached clientsets.
+func (c *kubeClientCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *kubeClientCache) evictExpired(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	for elem := c.lru.Back(); elem != nil; elem = c.lru.Back() {
+		if now.Sub(elem.Value.(*kubeClientCacheEntry).lastUsed) < c.ttl {
+			return
+		}
+		c.remove(elem)
+	}
+}
+
+func (c *kubeClientCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*kubeClientCacheEntry)
+	delete(c.entries, entry.impersonate)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestKubeClientCache(t *testing.T) {
+	now := time.Now()
+
+	c := newKubeClientCache(&rest.Config{Host: "http://localhost"}, 2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	get := func(impersonate string) kubernetes.Interface {
+		t.Helper()
+
+		client, err := c.Get(impersonate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	alice := get("alice")
+	if get("alice") != alice {
+		t.Error("clients should be reused per identity")
+	}
+	if get("") == alice {
+		t.Error("identities should get their own client")
+	}
+
+	// 'bob' evicts the least recently used, 'alice'
+	get("")
+	get("bob")
+	if want, got := 2, c.Len(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if get("alice") == alice {
+		t.Error("least recently used client should have been evicted")
+	}
+
+	// idle clients expire
+	now = now.Add(2 * time.Minute)
+	get("carol")
+	if want, got := 1, c.Len(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestKubeClientCacheNoTTL(t *testing.T) {
+	now := time.Now()
+
+	c := newKubeClientCache(&rest.Config{Host: "http://localhost"}, 0, 0)
+	c.now = func() time.Time { return now }
+
+	for _, impersonate := range []string{"", "alice", "bob"} {
+		if _, err := c.Get(impersonate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = now.Add(24 * time.Hour)
+	if _, err := c.Get("carol"); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 4, c.Len(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestKubeClientCacheImpersonation(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		impersonated []string
+	)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		impersonated = append(impersonated, r.Header.Get("Impersonate-User"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"Secret","apiVersion":"v1","metadata":{"name":"app","namespace":"default"}}`))
+	}))
+	t.Cleanup(api.Close)
+
+	c := newKubeClientCache(&rest.Config{Host: api.URL}, 0, 0)
+
+	for _, impersonate := range []string{"alice", ""} {
+		client, err := c.Get(impersonate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.CoreV1().Secrets("default").Get(context.Background(), "app", metav1.GetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(impersonated) != 2 || impersonated[0] != "alice" || impersonated[1] != "" {
+		t.Errorf("want requests as [alice, backend], got %q", impersonated)
+	}
+}
+
+// clientCacheForTest returns a cache already holding 'clients', by impersonated user.
+func clientCacheForTest(clients map[string]kubernetes.Interface) *kubeClientCache {
+	c := newKubeClientCache(&rest.Config{Host: "http://localhost"}, 0, 0)
+	for impersonate, client := range clients {
+		c.entries[impersonate] = c.lru.PushFront(&kubeClientCacheEntry{
+			impersonate: impersonate,
+			clientset:   client,
+			lastUsed:    c.now(),
+		})
+	}
+	return c
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nuid"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+// wascapRevision matches the claims revision of current wasmCloud hosts.
+const wascapRevision = 3
+
+// modulePrefixByte encodes 'M' keys, which nkeys doesn't know about.
+const modulePrefixByte = 12 << 3
+
+// mintOptions describe the test entity and host JWTs to sign.
+type mintOptions struct {
+	issuerSeed    *string
+	componentKey  *string
+	componentName *string
+	callAlias     *string
+	tags          *string
+	moduleHash    *string
+	hostKey       *string
+	hostName      *string
+	hostLabels    *string
+	expiry        *time.Duration
+}
+
+type mintedJWTs struct {
+	Entity string
+	Host   string
+}
+
+func mintFlags(flags *flag.FlagSet) *mintOptions {
+	return &mintOptions{
+		issuerSeed:    flags.String("issuer-seed", "", "Account seed signing the JWTs. Leave blank for an ephemeral account"),
+		componentKey:  flags.String("component-key", "", "Component ('M...') or provider ('V...') public key. Leave blank for an ephemeral module key"),
+		componentName: flags.String("component-name", "secrets-cli", "Component name"),
+		callAlias:     flags.String("call-alias", "", "Component call alias"),
+		tags:          flags.String("tags", "", "Comma separated component tags"),
+		moduleHash:    flags.String("module-hash", "", "Component module hash. Leave blank to derive one from the component name"),
+		hostKey:       flags.String("host-key", "", "Host ('N...') public key. Leave blank for an ephemeral server key"),
+		hostName:      flags.String("host-name", "secrets-cli", "Host name"),
+		hostLabels:    flags.String("host-labels", "", "Comma separated host labels, e.g. 'zone=prod,team=a'"),
+		expiry:        flags.Duration("jwt-expiry", time.Hour, "JWT lifetime. Zero mints JWTs without expiration"),
+	}
+}
+
+// Mint signs an entity and a host JWT with the issuer account.
+func (o *mintOptions) Mint() (*mintedJWTs, error) {
+	issuer, err := keyPairFromSeedOr(*o.issuerSeed, nkeys.PrefixByteAccount)
+	if err != nil {
+		return nil, fmt.Errorf("issuer: %w", err)
+	}
+
+	componentKey := *o.componentKey
+	if componentKey == "" {
+		componentKey, err = ephemeralModuleKey()
+		if err != nil {
+			return nil, fmt.Errorf("component key: %w", err)
+		}
+	}
+
+	hostKey, err := publicKeyOr(*o.hostKey, nkeys.PrefixByteServer)
+	if err != nil {
+		return nil, fmt.Errorf("host key: %w", err)
+	}
+
+	moduleHash := *o.moduleHash
+	if moduleHash == "" {
+		sum := sha256.Sum256([]byte(*o.componentName))
+		moduleHash = strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+
+	entity, err := o.sign(issuer, componentKey, map[string]interface{}{
+		"name":       *o.componentName,
+		"hash":       moduleHash,
+		"tags":       splitList(*o.tags),
+		"call_alias": *o.callAlias,
+		"prov":       componentKey[0] == 'V',
+	})
+	if err != nil {
+		return nil, fmt.Errorf("entity jwt: %w", err)
+	}
+
+	labels := make(map[string]string)
+	for _, label := range splitList(*o.hostLabels) {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok {
+			return nil, fmt.Errorf("host label '%s' isn't key=value", label)
+		}
+		labels[k] = v
+	}
+
+	host, err := o.sign(issuer, hostKey, map[string]interface{}{
+		"name":   *o.hostName,
+		"labels": labels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("host jwt: %w", err)
+	}
+
+	return &mintedJWTs{Entity: entity, Host: host}, nil
+}
+
+func (o *mintOptions) sign(issuer nkeys.KeyPair, subject string, claims map[string]interface{}) (string, error) {
+	issuerPubKey, err := issuer.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	wasCap := &secrets.WasCap{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       nuid.Next(),
+			Issuer:   issuerPubKey,
+			Subject:  subject,
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+		Was:      rawClaims,
+		Revision: wascapRevision,
+	}
+	if *o.expiry > 0 {
+		wasCap.ExpiresAt = jwt.NewNumericDate(now.Add(*o.expiry))
+	}
+
+	return jwt.NewWithClaims(secrets.SigningMethodEd25519, wasCap).SignedString(issuer)
+}
+
+// keyPairFromSeedOr parses 'seed', or creates an ephemeral key pair of type 'prefix' when blank.
+func keyPairFromSeedOr(seed string, prefix nkeys.PrefixByte) (nkeys.KeyPair, error) {
+	if seed == "" {
+		return nkeys.CreatePair(prefix)
+	}
+
+	return nkeys.FromSeed([]byte(seed))
+}
+
+// publicKeyOr returns 'key', or the public key of an ephemeral key pair of type 'prefix' when blank.
+func publicKeyOr(key string, prefix nkeys.PrefixByte) (string, error) {
+	if key != "" {
+		return key, nil
+	}
+
+	kp, err := nkeys.CreatePair(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	return kp.PublicKey()
+}
+
+// ephemeralModuleKey returns a random 'M' public key.
+func ephemeralModuleKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return encodePublicKey(modulePrefixByte, key), nil
+}
+
+// encodePublicKey encodes 'key' like nkeys: prefix, key and little endian crc16, in unpadded base32.
+func encodePublicKey(prefix byte, key []byte) string {
+	raw := make([]byte, 0, len(key)+3)
+	raw = append(raw, prefix)
+	raw = append(raw, key...)
+	raw = binary.LittleEndian.AppendUint16(raw, crc16(raw))
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+}
+
+// crc16 is the CRC-16/XMODEM checksum nkeys appends to keys.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/base32"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/nkeys"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+// module key from the wasmcloud host codebase
+const testModuleKey = "MC5CC4UD5LPDZ4C7ZNAEA4OZQ3BEFLSVQ742W3TET3ONKS4DRBVNM5IC"
+
+func TestEncodePublicKey(t *testing.T) {
+	kp, err := nkeys.CreateServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := kp.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := nkeys.Decode(nkeys.PrefixByteServer, []byte(pubKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := encodePublicKey(byte(nkeys.PrefixByteServer), key)
+	if want, got := pubKey, encoded; want != got {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// the encoded key verifies signatures of the original key pair
+	decoded, err := nkeys.FromPublicKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := kp.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify([]byte("data"), sig); err != nil {
+		t.Error(err)
+	}
+
+	// 'M' keys are encoded the same way by wasmCloud
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(testModuleKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := testModuleKey, encodePublicKey(modulePrefixByte, raw[1:len(raw)-2]); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestEphemeralModuleKey(t *testing.T) {
+	key, err := ephemeralModuleKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key[0] != 'M' || len(key) != len(testModuleKey) || !nkeys.IsValidEncoding([]byte(key)) {
+		t.Errorf("want a module key, got %v", key)
+	}
+
+	other, err := ephemeralModuleKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == other {
+		t.Error("keys should be random")
+	}
+}
+
+func TestMint(t *testing.T) {
+	issuer, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerSeed, err := issuer.Seed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerPubKey, err := issuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := secrets.ValidationOptions{
+		TrustedEntityIssuers: []string{issuerPubKey},
+		TrustedHostIssuers:   []string{issuerPubKey},
+		RequireExpiration:    true,
+	}
+
+	tests := map[string]struct {
+		args       []string
+		wantKey    string
+		wantTags   []string
+		wantLabels map[string]string
+		wantErr    bool
+	}{
+		"ephemeralKeys": {},
+		"claims": {
+			args:       []string{"-tags", "a,b", "-host-labels", "zone=prod,team=a"},
+			wantTags:   []string{"a", "b"},
+			wantLabels: map[string]string{"zone": "prod", "team": "a"},
+		},
+		"componentKey": {
+			args:    []string{"-component-key", testModuleKey},
+			wantKey: testModuleKey,
+		},
+		"noExpiry": {
+			args:    []string{"-jwt-expiry", "0"},
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			flags := flag.NewFlagSet("mint", flag.ContinueOnError)
+			mint := mintFlags(flags)
+			if err := flags.Parse(append([]string{"-issuer-seed", string(issuerSeed)}, test.args...)); err != nil {
+				t.Fatal(err)
+			}
+
+			minted, err := mint.Mint()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reqCtx := secrets.Context{EntityJwt: minted.Entity, HostJwt: minted.Host}
+			if respErr := reqCtx.Validate(opts); (respErr != nil) != test.wantErr {
+				t.Fatalf("want error %v, got %v", test.wantErr, respErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			entityCap, entityClaims, _ := reqCtx.EntityCapabilities()
+			if test.wantKey != "" && entityCap.Subject != test.wantKey {
+				t.Errorf("want %v, got %v", test.wantKey, entityCap.Subject)
+			}
+			if want, got := "secrets-cli", entityClaims.Name; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if want, got := test.wantTags, entityClaims.Tags; len(want) > 0 && !reflect.DeepEqual(want, got) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+
+			_, hostClaims, _ := reqCtx.HostCapabilities()
+			if want, got := test.wantLabels, hostClaims.Labels; len(want) > 0 && !reflect.DeepEqual(want, got) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Command secrets-cli talks to a wasmCloud secrets backend the way a host would, to debug backends and policies from a laptop.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+const usage = `Usage: secrets-cli [flags] <command> [command flags]
+
+Commands:
+  xkey                   Print the backend public xkey
+  get <key> [field]      Fetch a secret and print the decrypted response
+  list [key]             List the secrets and fields the policy can access
+  events                 Print change events published by the backend, until interrupted
+  jwt                    Print test entity and host JWTs
+
+Flags:
+`
+
+// PolicyType is the policy type hosts attach to requests.
+const PolicyType = "properties.secret.wasmcloud.dev/v1alpha1"
+
+type globalOptions struct {
+	natsURL   string
+	natsCreds string
+	backend   string
+	timeout   time.Duration
+}
+
+func main() {
+	opts := &globalOptions{}
+
+	flags := flag.NewFlagSet("secrets-cli", flag.ExitOnError)
+	flags.StringVar(&opts.natsURL, "nats-url", "nats://127.0.0.1:4222", "NATS Server URL")
+	flags.StringVar(&opts.natsCreds, "nats-creds", "", "NATS Credentials File")
+	flags.StringVar(&opts.backend, "backend", "kube", "Backend name, part of the secrets subject")
+	flags.DurationVar(&opts.timeout, "timeout", 5*time.Second, "Request timeout")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch command, args := flags.Arg(0), flags.Args()[1:]; command {
+	case "xkey":
+		err = runXkey(opts)
+	case "get":
+		err = runGet(opts, args)
+	case "list":
+		err = runList(opts, args)
+	case "events":
+		err = runEvents(opts)
+	case "jwt":
+		err = runJWT(args)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func (opts *globalOptions) client(clientOpts ...secrets.ClientOption) (*secrets.Client, error) {
+	natsConnectOps := []nats.Option{}
+	if opts.natsCreds != "" {
+		natsConnectOps = append(natsConnectOps, nats.UserCredentials(opts.natsCreds))
+	}
+
+	nc, err := nats.Connect(opts.natsURL, natsConnectOps...)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts = append(clientOpts, secrets.WithClientTimeout(opts.timeout))
+	return secrets.NewClient(opts.backend, nc, clientOpts...)
+}
+
+func runXkey(opts *globalOptions) error {
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	serverKey, err := client.ServerXkey(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(serverKey)
+	return nil
+}
+
+// requestOptions describe the sealed request sent by 'get' and 'list'.
+type requestOptions struct {
+	version     *string
+	application *string
+	policy      *string
+	xkeySeed    *string
+	entityJWT   *string
+	hostJWT     *string
+	mint        *mintOptions
+}
+
+func requestFlags(flags *flag.FlagSet) *requestOptions {
+	return &requestOptions{
+		version:     flags.String("version", "", "Secret version"),
+		application: flags.String("application", "secrets-cli", "Application name"),
+		policy:      flags.String("policy", "{}", "Policy properties as JSON, e.g. '{\"namespace\":\"default\"}'"),
+		xkeySeed:    flags.String("xkey-seed", "", "Host curve seed sealing the request. Leave blank for an ephemeral key"),
+		entityJWT:   flags.String("entity-jwt", "", "Entity JWT. Leave blank to mint one, see 'secrets-cli jwt -h'"),
+		hostJWT:     flags.String("host-jwt", "", "Host JWT. Leave blank to mint one, see 'secrets-cli jwt -h'"),
+		mint:        mintFlags(flags),
+	}
+}
+
+// request builds the request for 'key' and 'field', minting missing JWTs.
+func (o *requestOptions) request(key string, field string) (*secrets.Request, error) {
+	var policyProperties json.RawMessage
+	if err := json.Unmarshal([]byte(*o.policy), &policyProperties); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	policyJSON, err := json.Marshal(map[string]interface{}{
+		"type":       PolicyType,
+		"properties": policyProperties,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entityJWT, hostJWT := *o.entityJWT, *o.hostJWT
+	if entityJWT == "" || hostJWT == "" {
+		minted, err := o.mint.Mint()
+		if err != nil {
+			return nil, err
+		}
+		if entityJWT == "" {
+			entityJWT = minted.Entity
+		}
+		if hostJWT == "" {
+			hostJWT = minted.Host
+		}
+	}
+
+	return &secrets.Request{
+		Key:     key,
+		Field:   field,
+		Version: *o.version,
+		Context: secrets.Context{
+			Application: &secrets.ApplicationContext{
+				Name:   *o.application,
+				Policy: string(policyJSON),
+			},
+			EntityJwt: entityJWT,
+			HostJwt:   hostJWT,
+		},
+		Timestamp: time.Now().Unix(),
+	}, nil
+}
+
+// client connects to the backend, sealing requests with the given xkey if any.
+func (o *requestOptions) client(opts *globalOptions) (*secrets.Client, error) {
+	var clientOpts []secrets.ClientOption
+	if *o.xkeySeed != "" {
+		kp, err := nkeys.FromCurveSeed([]byte(*o.xkeySeed))
+		if err != nil {
+			return nil, fmt.Errorf("invalid xkey seed: %w", err)
+		}
+		clientOpts = append(clientOpts, secrets.WithClientKeyPair(kp))
+	}
+
+	return opts.client(clientOpts...)
+}
+
+func runGet(opts *globalOptions, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	reqOpts := requestFlags(flags)
+	_ = flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return errors.New("usage: secrets-cli get [flags] <key> [field]")
+	}
+
+	req, err := reqOpts.request(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	client, err := reqOpts.client(opts)
+	if err != nil {
+		return err
+	}
+
+	value, err := client.Get(context.Background(), req)
+
+	var resp secrets.Response
+	resp.Secret = value
+	if resp.Error, err = responseError(err); err != nil {
+		return err
+	}
+
+	return writeResponse(resp, resp.Error)
+}
+
+func runList(opts *globalOptions, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	reqOpts := requestFlags(flags)
+	_ = flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		return errors.New("usage: secrets-cli list [flags] [key]")
+	}
+
+	req, err := reqOpts.request(flags.Arg(0), "")
+	if err != nil {
+		return err
+	}
+
+	client, err := reqOpts.client(opts)
+	if err != nil {
+		return err
+	}
+
+	listings, err := client.List(context.Background(), req)
+
+	var resp secrets.ListResponse
+	resp.Secrets = listings
+	if resp.Error, err = responseError(err); err != nil {
+		return err
+	}
+
+	return writeResponse(resp, resp.Error)
+}
+
+// responseError splits backend errors, which are printed as part of the response, from other failures.
+func responseError(err error) (*secrets.ResponseError, error) {
+	var respErr *secrets.ResponseError
+	if err == nil || errors.As(err, &respErr) {
+		return respErr, nil
+	}
+	return nil, err
+}
+
+// writeResponse prints the response, exiting with status 1 if it holds an error.
+func writeResponse(resp interface{ Write(io.Writer) error }, respErr *secrets.ResponseError) error {
+	if err := resp.Write(os.Stdout); err != nil {
+		return err
+	}
+
+	if respErr != nil {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runEvents(opts *globalOptions) error {
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	sub, err := client.SubscribeEvents(func(event *secrets.ChangeEvent) {
+		_ = enc.Encode(event)
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	<-ctx.Done()
+	return nil
+}
+
+func runJWT(args []string) error {
+	flags := flag.NewFlagSet("jwt", flag.ExitOnError)
+	mint := mintFlags(flags)
+	_ = flags.Parse(args)
+
+	minted, err := mint.Mint()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("entity:", minted.Entity)
+	fmt.Println("host:  ", minted.Host)
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultMaxTrackedSecrets caps how many Secrets are watched for change events.
+const DefaultMaxTrackedSecrets = 1024
+
+// changeNotifier watches the Secrets the backend has served and publishes a secrets.ChangeEvent when they change.
+// Each served Secret is watched on its own, with the credentials it was read with.
+// Watches only keep field hashes, never values.
+type changeNotifier struct {
+	sync.Mutex
+	ctx        context.Context
+	clients    func(impersonate string) (kubernetes.Interface, error)
+	nc         *nats.Conn
+	subject    string
+	hashKey    []byte
+	maxTracked int
+
+	tracked map[string]*trackedSecret
+	warned  bool
+}
+
+// trackedSecret is the watch of one served Secret.
+type trackedSecret struct {
+	factory informers.SharedInformerFactory
+	cancel  context.CancelFunc
+}
+
+// newChangeNotifier publishes events on 'subject' until 'ctx' is done.
+// Field hashes are keyed with 'hashKey', so replicas sharing it publish the same hashes.
+func newChangeNotifier(ctx context.Context, clients func(string) (kubernetes.Interface, error), nc *nats.Conn, subject string, hashKey []byte, maxTracked int) *changeNotifier {
+	if maxTracked <= 0 {
+		maxTracked = DefaultMaxTrackedSecrets
+	}
+
+	return &changeNotifier{
+		ctx:        ctx,
+		clients:    clients,
+		nc:         nc,
+		subject:    subject,
+		hashKey:    hashKey,
+		maxTracked: maxTracked,
+		tracked:    make(map[string]*trackedSecret),
+	}
+}
+
+func trackedSecretKey(impersonate string, namespace string, name string) string {
+	return impersonate + "/" + namespace + "/" + name
+}
+
+// Track records that a Secret was served, watching it if needed. Safe to call on a nil receiver.
+func (n *changeNotifier) Track(impersonate string, namespace string, name string) {
+	if n == nil {
+		return
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	key := trackedSecretKey(impersonate, namespace, name)
+	if _, ok := n.tracked[key]; ok {
+		return
+	}
+
+	if len(n.tracked) >= n.maxTracked {
+		if !n.warned {
+			slog.Warn("Too many secrets watched for changes, ignoring new ones", slog.Int("max", n.maxTracked))
+			n.warned = true
+		}
+		return
+	}
+
+	client, err := n.clients(impersonate)
+	if err != nil {
+		slog.Error("Couldn't watch secret for changes", slog.String("namespace", namespace), slog.String("key", name), slog.Any("error", err))
+		return
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+
+	informer := factory.Core().V1().Secrets().Informer()
+	if err := informer.SetTransform(n.transform); err != nil {
+		slog.Error("Couldn't watch secret for changes", slog.String("namespace", namespace), slog.String("key", name), slog.Any("error", err))
+		return
+	}
+
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: n.onUpdate,
+		DeleteFunc: func(obj interface{}) { n.onDelete(key, obj) },
+	})
+	if err != nil {
+		slog.Error("Couldn't watch secret for changes", slog.String("namespace", namespace), slog.String("key", name), slog.Any("error", err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(n.ctx)
+	factory.Start(ctx.Done())
+	n.tracked[key] = &trackedSecret{factory: factory, cancel: cancel}
+	slog.Debug("Watching secret for changes", slog.String("namespace", namespace), slog.String("key", name))
+}
+
+// Shutdown stops the watches and waits for them to exit.
+func (n *changeNotifier) Shutdown() {
+	n.Lock()
+	tracked := n.tracked
+	n.tracked = make(map[string]*trackedSecret)
+	n.Unlock()
+
+	// event handlers take the lock, wait for them without holding it
+	for _, t := range tracked {
+		t.cancel()
+		t.factory.Shutdown()
+	}
+}
+
+// untrack stops watching a Secret. It doesn't wait, as it runs from the watch's own event handler.
+func (n *changeNotifier) untrack(key string) {
+	n.Lock()
+	defer n.Unlock()
+
+	if tracked, ok := n.tracked[key]; ok {
+		tracked.cancel()
+		delete(n.tracked, key)
+	}
+}
+
+// transform replaces Secret values with their hashes before the watch caches them, keeping only the metadata events need.
+// Field hashes are kept in 'StringData'. Objects can go through it more than once, already hashed Secrets have no 'Data'.
+func (n *changeNotifier) transform(obj interface{}) (interface{}, error) {
+	kubeSecret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return obj, nil
+	}
+
+	hashes := kubeSecret.StringData
+	if kubeSecret.Data != nil {
+		hashes = n.fieldHashes(kubeSecret.Data)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            kubeSecret.Name,
+			Namespace:       kubeSecret.Namespace,
+			ResourceVersion: kubeSecret.ResourceVersion,
+		},
+		StringData: hashes,
+	}, nil
+}
+
+func (n *changeNotifier) onUpdate(oldObj interface{}, newObj interface{}) {
+	oldSecret, ok := oldObj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	newSecret, ok := newObj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+
+	// resyncs and metadata-only updates don't change what components read
+	changed := changedFields(oldSecret.StringData, newSecret.StringData)
+	if len(changed) == 0 {
+		return
+	}
+
+	n.publish(&secrets.ChangeEvent{
+		Type:      secrets.ChangeEventUpdated,
+		Key:       newSecret.Name,
+		Namespace: newSecret.Namespace,
+		Version:   newSecret.ResourceVersion,
+		Fields:    newSecret.StringData,
+		Changed:   changed,
+		Time:      time.Now().UTC(),
+	}, newSecret.ResourceVersion)
+}
+
+func (n *changeNotifier) onDelete(key string, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	kubeSecret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	n.untrack(key)
+
+	n.publish(&secrets.ChangeEvent{
+		Type:      secrets.ChangeEventDeleted,
+		Key:       kubeSecret.Name,
+		Namespace: kubeSecret.Namespace,
+		Changed:   changedFields(kubeSecret.StringData, nil),
+		Time:      time.Now().UTC(),
+	}, kubeSecret.ResourceVersion+"-deleted")
+}
+
+// publish sends the event with a message id, so JetStream streams capturing the subject drop copies published by other replicas.
+func (n *changeNotifier) publish(event *secrets.ChangeEvent, revision string) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Couldn't encode change event", slog.Any("error", err))
+		return
+	}
+
+	msg := nats.NewMsg(n.subject)
+	msg.Header.Set(nats.MsgIdHdr, event.Namespace+"/"+event.Key+"@"+revision)
+	msg.Data = data
+
+	if err := n.nc.PublishMsg(msg); err != nil {
+		slog.Error("Couldn't publish change event", slog.String("namespace", event.Namespace), slog.String("key", event.Key), slog.Any("error", err))
+		return
+	}
+
+	slog.Info("Published change event", slog.String("type", event.Type), slog.String("namespace", event.Namespace), slog.String("key", event.Key), slog.Any("changed", event.Changed))
+}
+
+// fieldHashes hashes every field with HMAC-SHA256, so low entropy values can't be guessed from their hash.
+func (n *changeNotifier) fieldHashes(data map[string][]byte) map[string]string {
+	hashes := make(map[string]string, len(data))
+	for field, value := range data {
+		mac := hmac.New(sha256.New, n.hashKey)
+		mac.Write(value)
+		hashes[field] = hex.EncodeToString(mac.Sum(nil))
+	}
+	return hashes
+}
+
+// changedFields returns the sorted fields added, updated or removed between the 'before' and 'after' field hashes.
+func changedFields(before map[string]string, after map[string]string) []string {
+	var changed []string
+	for field, hash := range after {
+		if previous, ok := before[field]; !ok || previous != hash {
+			changed = append(changed, field)
+		}
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// readEventHashKey derives the field hash key from the contents of 'path', which replicas share and hosts don't know.
+// The key doesn't depend on the backend xkeys, so hashes stay the same across key rotations.
+// A blank path gives a random key: hashes then differ between replicas and restarts.
+func readEventHashKey(path string) ([]byte, error) {
+	if path == "" {
+		key := make([]byte, sha256.Size)
+		_, err := rand.Read(key)
+		return key, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := bytes.TrimSpace(data)
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%s: empty hash key", path)
+	}
+
+	return eventHashKey(secret), nil
+}
+
+func eventHashKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("wasmcloud-secrets-change-events"))
+	return mac.Sum(nil)
+}
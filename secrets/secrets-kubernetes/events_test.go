@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func natsConnectionForTest(t *testing.T) *nats.Conn {
+	t.Helper()
+
+	s := natsserver.RunRandClientPortServer()
+	t.Cleanup(s.Shutdown)
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+
+	return nc
+}
+
+func kubeSecretForTest(name string, data map[string]string) *corev1.Secret {
+	kubeSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{AllowedTagsAnnotation: "prod"},
+		},
+		Data: make(map[string][]byte),
+	}
+	for field, value := range data {
+		kubeSecret.Data[field] = []byte(value)
+	}
+	return kubeSecret
+}
+
+// changeEventsForTest subscribes to the events published on 'subject'.
+func changeEventsForTest(t *testing.T, nc *nats.Conn, subject string) <-chan *secrets.ChangeEvent {
+	t.Helper()
+
+	events := make(chan *secrets.ChangeEvent, 8)
+	sub, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+		event := &secrets.ChangeEvent{}
+		if err := json.Unmarshal(msg.Data, event); err != nil {
+			t.Error(err)
+			return
+		}
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sub.Unsubscribe() })
+
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+func nextChangeEventForTest(t *testing.T, events <-chan *secrets.ChangeEvent) *secrets.ChangeEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+		return nil
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	tests := map[string]struct {
+		before map[string]string
+		after  map[string]string
+		want   []string
+	}{
+		"unchanged": {
+			before: map[string]string{"a": "1", "b": "2"},
+			after:  map[string]string{"a": "1", "b": "2"},
+		},
+		"updated": {
+			before: map[string]string{"a": "1", "b": "2"},
+			after:  map[string]string{"a": "1", "b": "3"},
+			want:   []string{"b"},
+		},
+		"addedAndRemoved": {
+			before: map[string]string{"a": "1", "c": "3"},
+			after:  map[string]string{"b": "2", "c": "3"},
+			want:   []string{"a", "b"},
+		},
+		"deleted": {
+			before: map[string]string{"b": "2", "a": "1"},
+			want:   []string{"a", "b"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if want, got := test.want, changedFields(test.before, test.after); !reflect.DeepEqual(want, got) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestChangeNotifierTransform(t *testing.T) {
+	n := newChangeNotifier(context.Background(), nil, nil, "", []byte("key"), 0)
+
+	obj, err := n.transform(kubeSecretForTest("app", map[string]string{"password": "hunter2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformed := obj.(*corev1.Secret)
+
+	if transformed.Data != nil {
+		t.Error("values shouldn't be kept")
+	}
+	if transformed.Annotations != nil {
+		t.Error("unused metadata shouldn't be kept")
+	}
+	if want, got := "1", transformed.ResourceVersion; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	hash := transformed.StringData["password"]
+	if hash == "" || hash == "hunter2" {
+		t.Errorf("want a hash, got %q", hash)
+	}
+
+	// objects can go through the transform again
+	again, err := n.transform(transformed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transformed, again) {
+		t.Errorf("want %v, got %v", transformed, again)
+	}
+
+	// other keys give other hashes
+	other := newChangeNotifier(context.Background(), nil, nil, "", []byte("other"), 0)
+	if other.fieldHashes(map[string][]byte{"password": []byte("hunter2")})["password"] == hash {
+		t.Error("hashes should depend on the key")
+	}
+}
+
+func TestChangeNotifier(t *testing.T) {
+	nc := natsConnectionForTest(t)
+	events := changeEventsForTest(t, nc, "events")
+
+	client := fake.NewSimpleClientset(kubeSecretForTest("app", map[string]string{"password": "hunter2", "username": "admin"}))
+
+	var (
+		mu           sync.Mutex
+		impersonated []string
+	)
+	clients := func(impersonate string) (kubernetes.Interface, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		impersonated = append(impersonated, impersonate)
+		return client, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := newChangeNotifier(ctx, clients, nc, "events", []byte("key"), 1)
+	t.Cleanup(func() {
+		cancel()
+		n.Shutdown()
+	})
+
+	n.Track("app-reader", "default", "app")
+	n.Track("app-reader", "default", "app")
+	// over the limit
+	n.Track("app-reader", "default", "other")
+
+	mu.Lock()
+	if want, got := []string{"app-reader"}, impersonated; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	mu.Unlock()
+
+	key := trackedSecretKey("app-reader", "default", "app")
+	n.Lock()
+	tracked, ok := n.tracked[key]
+	trackedCount := len(n.tracked)
+	n.Unlock()
+	if !ok || trackedCount != 1 {
+		t.Fatalf("want only %v tracked, got %v", key, trackedCount)
+	}
+	tracked.factory.WaitForCacheSync(ctx.Done())
+
+	secretsClient := client.CoreV1().Secrets("default")
+
+	// metadata-only updates aren't published
+	updated := kubeSecretForTest("app", map[string]string{"password": "hunter2", "username": "admin"})
+	updated.ResourceVersion = "2"
+	updated.Labels = map[string]string{"team": "a"}
+	if _, err := secretsClient.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated = kubeSecretForTest("app", map[string]string{"password": "correct horse", "username": "admin"})
+	updated.ResourceVersion = "3"
+	if _, err := secretsClient.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextChangeEventForTest(t, events)
+	if want, got := secrets.ChangeEventUpdated, event.Type; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want, got := "3", event.Version; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want, got := []string{"password"}, event.Changed; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want, got := n.fieldHashes(updated.Data), event.Fields; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if err := secretsClient.Delete(ctx, "app", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	event = nextChangeEventForTest(t, events)
+	if want, got := secrets.ChangeEventDeleted, event.Type; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want, got := []string{"password", "username"}, event.Changed; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// deleted Secrets aren't watched anymore, making room for others
+	n.Track("app-reader", "default", "other")
+
+	n.Lock()
+	_, ok = n.tracked[key]
+	_, otherOk := n.tracked[trackedSecretKey("app-reader", "default", "other")]
+	n.Unlock()
+	if ok {
+		t.Error("deleted secret should be untracked")
+	}
+	if !otherOk {
+		t.Error("other secret should be tracked once there is room")
+	}
+}
+
+func TestChangeNotifierDeleteTombstone(t *testing.T) {
+	nc := natsConnectionForTest(t)
+	events := changeEventsForTest(t, nc, "events")
+
+	n := newChangeNotifier(context.Background(), nil, nc, "events", []byte("key"), 0)
+
+	key := trackedSecretKey("", "default", "app")
+	n.tracked[key] = &trackedSecret{cancel: func() {}}
+
+	obj, err := n.transform(kubeSecretForTest("app", map[string]string{"password": "hunter2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.onDelete(key, cache.DeletedFinalStateUnknown{Key: "default/app", Obj: obj})
+
+	event := nextChangeEventForTest(t, events)
+	if want, got := secrets.ChangeEventDeleted, event.Type; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want, got := "app", event.Key; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if _, ok := n.tracked[key]; ok {
+		t.Error("deleted secret should be untracked")
+	}
+}
+
+func TestReadEventHashKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hash-key")
+	if err := os.WriteFile(path, []byte("shared secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := readEventHashKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replicas and restarts reading the same file get the same key
+	again, err := readEventHashKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("keys read from the same file should match")
+	}
+	if bytes.Contains(key, []byte("shared secret")) {
+		t.Error("the file contents shouldn't be used as is")
+	}
+
+	random, err := readEventHashKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(random) != len(key) || bytes.Equal(random, key) {
+		t.Errorf("want a random key, got %x", random)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEventHashKey(empty); err == nil {
+		t.Error("empty files should be rejected")
+	}
+	if _, err := readEventHashKey(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing files should be rejected")
+	}
+}
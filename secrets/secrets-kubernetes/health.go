@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type healthCheck func(ctx context.Context) error
+
+// probeHandler answers 200 when all checks pass, 503 with the first failure otherwise.
+func probeHandler(timeout time.Duration, checks ...healthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		for _, check := range checks {
+			if err := check(ctx); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+}
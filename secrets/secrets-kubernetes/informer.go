@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listercorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// kubeSecretInformer serves Secrets from shared informer caches, one per watched namespace.
+// Caches are kept up to date by the API server watch, so reads don't hit the API server
+// and keep working through brief API server outages.
+type kubeSecretInformer struct {
+	factories map[string]informers.SharedInformerFactory
+	listers   map[string]listercorev1.SecretLister
+	synced    []cache.InformerSynced
+}
+
+// newKubeSecretInformer prepares informers for the given namespaces.
+// An empty namespace list watches all namespaces.
+// The label selector, if not blank, restricts which Secrets are cached.
+func newKubeSecretInformer(client kubernetes.Interface, namespaces []string, labelSelector string, resync time.Duration) *kubeSecretInformer {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	i := &kubeSecretInformer{
+		factories: make(map[string]informers.SharedInformerFactory),
+		listers:   make(map[string]listercorev1.SecretLister),
+	}
+
+	for _, ns := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+			informers.WithNamespace(ns),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = labelSelector
+			}),
+		)
+		secretInformer := factory.Core().V1().Secrets()
+
+		i.factories[ns] = factory
+		i.listers[ns] = secretInformer.Lister()
+		i.synced = append(i.synced, secretInformer.Informer().HasSynced)
+	}
+
+	return i
+}
+
+// Start runs the informers until the context is cancelled and waits for the initial sync.
+func (i *kubeSecretInformer) Start(ctx context.Context) error {
+	for _, factory := range i.factories {
+		factory.Start(ctx.Done())
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), i.synced...) {
+		return fmt.Errorf("failed to sync secret informers")
+	}
+
+	return nil
+}
+
+// Shutdown stops the informers and waits for them to exit.
+// The context passed to Start must be cancelled first.
+func (i *kubeSecretInformer) Shutdown() {
+	for _, factory := range i.factories {
+		factory.Shutdown()
+	}
+}
+
+// Lookup returns a cached Secret. The second return value is false when the
+// namespace is not watched or the Secret is not in the cache.
+func (i *kubeSecretInformer) Lookup(namespace string, name string) (*corev1.Secret, bool) {
+	lister, ok := i.listers[namespace]
+	if !ok {
+		lister, ok = i.listers[metav1.NamespaceAll]
+	}
+	if !ok {
+		return nil, false
+	}
+
+	secret, err := lister.Secrets(namespace).Get(name)
+	if err != nil {
+		return nil, false
+	}
+
+	return secret, true
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// countingClientForTest returns a fake clientset holding 'objects', counting the Secrets read with a live GET.
+func countingClientForTest(gets *atomic.Int32, objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		gets.Add(1)
+		return false, nil, nil
+	})
+	return client
+}
+
+func startedInformerForTest(t *testing.T, client kubernetes.Interface, namespaces []string, labelSelector string) *kubeSecretInformer {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	informer := newKubeSecretInformer(client, namespaces, labelSelector, time.Minute)
+	t.Cleanup(func() {
+		cancel()
+		informer.Shutdown()
+	})
+
+	if err := informer.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return informer
+}
+
+func TestKubeSecretInformerLookup(t *testing.T) {
+	app := kubeSecretForTest("app", map[string]string{"password": "hunter2"})
+	app.Labels = map[string]string{"cached": "true"}
+	unlabelled := kubeSecretForTest("unlabelled", map[string]string{"password": "hunter2"})
+	other := kubeSecretForTest("other", map[string]string{"password": "hunter2"})
+	other.Namespace = "other"
+	other.Labels = map[string]string{"cached": "true"}
+
+	client := fake.NewSimpleClientset(app, unlabelled, other)
+
+	tests := map[string]struct {
+		namespaces []string
+		namespace  string
+		name       string
+		found      bool
+	}{
+		"hit": {
+			namespaces: []string{"default"},
+			namespace:  "default",
+			name:       "app",
+			found:      true,
+		},
+		"missing": {
+			namespaces: []string{"default"},
+			namespace:  "default",
+			name:       "missing",
+		},
+		"labelSelector": {
+			namespaces: []string{"default"},
+			namespace:  "default",
+			name:       "unlabelled",
+		},
+		"unwatchedNamespace": {
+			namespaces: []string{"default"},
+			namespace:  "other",
+			name:       "other",
+		},
+		"allNamespaces": {
+			namespace: "other",
+			name:      "other",
+			found:     true,
+		},
+		"allNamespacesScoped": {
+			namespace: "default",
+			name:      "other",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			informer := startedInformerForTest(t, client, test.namespaces, "cached=true")
+
+			kubeSecret, ok := informer.Lookup(test.namespace, test.name)
+			if ok != test.found {
+				t.Fatalf("want found %v, got %v", test.found, ok)
+			}
+			if ok && (kubeSecret.Namespace != test.namespace || kubeSecret.Name != test.name) {
+				t.Errorf("want %v/%v, got %v/%v", test.namespace, test.name, kubeSecret.Namespace, kubeSecret.Name)
+			}
+		})
+	}
+}
+
+func TestFetchSecretInformer(t *testing.T) {
+	app := kubeSecretForTest("app", map[string]string{"password": "hunter2"})
+	app.Labels = map[string]string{"cached": "true"}
+	unlabelled := kubeSecretForTest("unlabelled", map[string]string{"password": "hunter2"})
+
+	tests := map[string]struct {
+		started     bool
+		impersonate string
+		name        string
+		wantGets    int32
+	}{
+		"cacheHit": {
+			started: true,
+			name:    "app",
+		},
+		"cacheMiss": {
+			started:  true,
+			name:     "unlabelled",
+			wantGets: 1,
+		},
+		"notSynced": {
+			name:     "app",
+			wantGets: 1,
+		},
+		"impersonated": {
+			started:     true,
+			impersonate: "app-reader",
+			name:        "app",
+			wantGets:    1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gets, impersonatedGets atomic.Int32
+			client := countingClientForTest(&gets, app, unlabelled)
+			impersonatedClient := countingClientForTest(&impersonatedGets, app, unlabelled)
+
+			informer := newKubeSecretInformer(client, []string{"default"}, "cached=true", time.Minute)
+			if test.started {
+				informer = startedInformerForTest(t, client, []string{"default"}, "cached=true")
+			}
+
+			s := &kubeSecretsServer{
+				clients:  clientCacheForTest(map[string]kubernetes.Interface{"": client, "app-reader": impersonatedClient}),
+				informer: informer,
+			}
+
+			kubeSecret, err := s.fetchSecret(context.Background(), &kubeApplicationPolicy{Namespace: "default", Impersonate: test.impersonate}, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, got := test.name, kubeSecret.Name; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+
+			got := gets.Load()
+			if test.impersonate != "" {
+				got = impersonatedGets.Load()
+				if gets.Load() != 0 {
+					t.Error("impersonated reads should use the impersonated client")
+				}
+			}
+			if want := test.wantGets; want != got {
+				t.Errorf("want %v live reads, got %v", want, got)
+			}
+		})
+	}
+}
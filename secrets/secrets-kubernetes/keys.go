@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nats-io/nkeys"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// serverKeySource loads the backend xkeys. The first key is the primary one, advertised to hosts, the others are retired.
+type serverKeySource interface {
+	Load(ctx context.Context) ([]nkeys.KeyPair, error)
+	// Watch calls 'changed' whenever the keys may have changed, until 'ctx' is done.
+	Watch(ctx context.Context, changed func()) error
+}
+
+// seedKeySource uses seeds given on the command line. They can't change.
+type seedKeySource struct {
+	seeds []string
+}
+
+func (s *seedKeySource) Load(context.Context) ([]nkeys.KeyPair, error) {
+	return parseKeySeeds([]byte(strings.Join(s.seeds, "\n")))
+}
+
+func (s *seedKeySource) Watch(context.Context, func()) error {
+	return nil
+}
+
+// fileKeySource reads seeds from a file, one per line.
+type fileKeySource struct {
+	path string
+}
+
+func (s *fileKeySource) Load(context.Context) ([]nkeys.KeyPair, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeySeeds(data)
+}
+
+// Watch watches the parent directory, as Kubernetes updates mounted Secrets by swapping symlinks.
+func (s *fileKeySource) Watch(ctx context.Context, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				changed()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("Couldn't watch xkeys file", slog.String("path", s.path), slog.Any("error", err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// secretKeySource reads seeds, one per line, from a Kubernetes Secret field.
+type secretKeySource struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	field     string
+}
+
+func (s *secretKeySource) Load(ctx context.Context) ([]nkeys.KeyPair, error) {
+	kubeSecret, err := s.client.CoreV1().Secrets(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := kubeSecret.Data[s.field]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no '%s' field", s.namespace, s.name, s.field)
+	}
+
+	return parseKeySeeds(data)
+}
+
+// Watch runs an informer restricted to the Secret.
+func (s *secretKeySource) Watch(ctx context.Context, changed func()) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(s.client, 0,
+		informers.WithNamespace(s.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", s.name).String()
+		}),
+	)
+
+	_, err := factory.Core().V1().Secrets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, _ interface{}) { changed() },
+		AddFunc:    func(interface{}) { changed() },
+	})
+	if err != nil {
+		return err
+	}
+
+	factory.Start(ctx.Done())
+	go func() {
+		<-ctx.Done()
+		factory.Shutdown()
+	}()
+
+	return nil
+}
+
+// parseKeySeeds parses xkey seeds, one per line. Blank lines and '#' comments are skipped.
+func parseKeySeeds(data []byte) ([]nkeys.KeyPair, error) {
+	var kps []nkeys.KeyPair
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kp, err := nkeys.FromCurveSeed([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("seed %d: %w", len(kps), err)
+		}
+		kps = append(kps, kp)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(kps) == 0 {
+		return nil, errors.New("no seeds")
+	}
+
+	return kps, nil
+}
+
+// parseSecretRef parses a 'namespace/name:key' Kubernetes Secret reference.
+func parseSecretRef(ref string) (namespace string, name string, field string, err error) {
+	namespace, rest, ok := strings.Cut(ref, "/")
+	if !ok {
+		return "", "", "", fmt.Errorf("'%s' isn't a namespace/name:key reference", ref)
+	}
+
+	name, field, ok = strings.Cut(rest, ":")
+	if !ok || namespace == "" || name == "" || field == "" {
+		return "", "", "", fmt.Errorf("'%s' isn't a namespace/name:key reference", ref)
+	}
+
+	return namespace, name, field, nil
+}
+
+// DefaultRetiredKeyRetention is how long rotated out keys keep decrypting requests by default.
+const DefaultRetiredKeyRetention = 24 * time.Hour
+
+// retiredKey is a former primary key, kept until hosts stop using it.
+type retiredKey struct {
+	kp        nkeys.KeyPair
+	pubKey    string
+	retiredAt time.Time
+}
+
+// keyRotator tracks the keys loaded from a source.
+// When the primary key changes, the previous one is retired for 'retention', so hosts that fetched it keep working
+// however many rotations happen meanwhile. Expired keys are dropped on the next reload.
+type keyRotator struct {
+	sync.Mutex
+	source    serverKeySource
+	retention time.Duration
+	now       func() time.Time
+	primary   nkeys.KeyPair
+	retired   []retiredKey
+}
+
+func newKeyRotator(source serverKeySource, retention time.Duration) *keyRotator {
+	return &keyRotator{source: source, retention: retention, now: time.Now}
+}
+
+// Keys loads the primary and retired keys.
+func (r *keyRotator) Keys(ctx context.Context) (nkeys.KeyPair, []nkeys.KeyPair, bool, error) {
+	kps, err := r.source.Load(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	loaded := make(map[string]bool, len(kps))
+	for _, kp := range kps {
+		pubKey, err := kp.PublicKey()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		loaded[pubKey] = true
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	rotated := false
+	if r.primary != nil {
+		current, err := r.primary.PublicKey()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		next, err := kps[0].PublicKey()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if current != next {
+			r.retired = append(r.retired, retiredKey{kp: r.primary, pubKey: current, retiredAt: now})
+			rotated = true
+		}
+	}
+	r.primary = kps[0]
+
+	// keys still in the source don't need to be tracked
+	kept := r.retired[:0]
+	for _, key := range r.retired {
+		if !loaded[key.pubKey] && now.Sub(key.retiredAt) < r.retention {
+			kept = append(kept, key)
+		}
+	}
+	r.retired = kept
+
+	retired := append([]nkeys.KeyPair{}, kps[1:]...)
+	for _, key := range r.retired {
+		retired = append(retired, key.kp)
+	}
+
+	return r.primary, retired, rotated, nil
+}
+
+// Reload loads the keys and installs them on 'server'.
+func (r *keyRotator) Reload(ctx context.Context, server *secrets.Server) error {
+	primary, retired, rotated, err := r.Keys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := server.SetKeys(primary, retired...); err != nil {
+		return err
+	}
+
+	if rotated {
+		slog.Info("Rotated xkeys", slog.String("primary", server.PublicKey()), slog.Int("retired", len(retired)))
+	}
+	return nil
+}
+
+// Watch reloads the keys when the source changes or the process receives SIGHUP, until 'ctx' is done.
+func (r *keyRotator) Watch(ctx context.Context, server *secrets.Server) error {
+	reload := func() {
+		if err := r.Reload(ctx, server); err != nil {
+			slog.Error("Couldn't reload xkeys", slog.Any("error", err))
+		}
+	}
+
+	if err := r.source.Watch(ctx, reload); err != nil {
+		return err
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sighup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				reload()
+			}
+		}
+	}()
+
+	return nil
+}
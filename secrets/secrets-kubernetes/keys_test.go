@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+// staticKeySource serves keys set by the test.
+type staticKeySource struct {
+	kps []nkeys.KeyPair
+}
+
+func (s *staticKeySource) Load(context.Context) ([]nkeys.KeyPair, error) {
+	return s.kps, nil
+}
+
+func (s *staticKeySource) Watch(context.Context, func()) error {
+	return nil
+}
+
+func curveKeyForTest(t *testing.T) nkeys.KeyPair {
+	t.Helper()
+
+	kp, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kp
+}
+
+func publicKeysForTest(t *testing.T, kps []nkeys.KeyPair) []string {
+	t.Helper()
+
+	var pubKeys []string
+	for _, kp := range kps {
+		pubKey, err := kp.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	return pubKeys
+}
+
+func TestKeyRotator(t *testing.T) {
+	first, second, third := curveKeyForTest(t), curveKeyForTest(t), curveKeyForTest(t)
+
+	source := &staticKeySource{kps: []nkeys.KeyPair{first}}
+	now := time.Now()
+
+	r := newKeyRotator(source, time.Hour)
+	r.now = func() time.Time { return now }
+
+	keys := func(wantRotated bool, want ...nkeys.KeyPair) {
+		t.Helper()
+
+		primary, retired, rotated, err := r.Keys(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated != wantRotated {
+			t.Errorf("want rotated %v, got %v", wantRotated, rotated)
+		}
+
+		got := publicKeysForTest(t, append([]nkeys.KeyPair{primary}, retired...))
+		if want := publicKeysForTest(t, want); len(want) != len(got) {
+			t.Errorf("want %v, got %v", want, got)
+		} else {
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("want %v, got %v", want, got)
+					break
+				}
+			}
+		}
+	}
+
+	keys(false, first)
+
+	// two rotations in a row keep both previous keys
+	source.kps = []nkeys.KeyPair{second}
+	now = now.Add(time.Minute)
+	keys(true, second, first)
+
+	source.kps = []nkeys.KeyPair{third}
+	now = now.Add(time.Minute)
+	keys(true, third, first, second)
+
+	// retired keys are dropped once the retention has passed
+	now = now.Add(59 * time.Minute)
+	keys(false, third, second)
+
+	// keys listed by the source aren't tracked twice
+	source.kps = []nkeys.KeyPair{third, second}
+	keys(false, third, second)
+}
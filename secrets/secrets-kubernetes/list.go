@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ secrets.Lister = &kubeSecretsServer{}
+
+// List returns the Secrets of the policy namespace the entity can read, with their field names.
+// Secrets are always listed from the API server, as the informer cache may only hold some of them.
+func (s *kubeSecretsServer) List(ctx context.Context, r *secrets.Request) ([]secrets.SecretListing, error) {
+	policy, err := parseApplicationPolicy(r.Context)
+	if err != nil {
+		return nil, secrets.ErrPolicy.With(err.Error())
+	}
+	slog.Info("List", slog.String("application", r.Context.Application.Name), slog.String("impersonate", policy.Impersonate), slog.String("key", r.Key))
+
+	if err := r.Context.AuthorizeHost(policy.HostRule); err != nil {
+		return nil, err
+	}
+
+	var kubeSecrets []corev1.Secret
+	if r.Key != "" {
+		kubeSecret, err := s.fetchSecret(ctx, policy, r.Key)
+		if apierrors.IsNotFound(err) {
+			return nil, secrets.ErrSecretNotFound
+		}
+		if err != nil {
+			return nil, secrets.ErrUpstream.With(err.Error())
+		}
+		kubeSecrets = append(kubeSecrets, *kubeSecret)
+	} else {
+		kubeSecrets, err = s.listSecrets(ctx, policy)
+		if err != nil {
+			return nil, secrets.ErrUpstream.With(err.Error())
+		}
+	}
+
+	listings := []secrets.SecretListing{}
+	for i := range kubeSecrets {
+		kubeSecret := &kubeSecrets[i]
+		if err := r.Context.AuthorizeEntity(policy.EntityRule, secretEntityRule(kubeSecret)); err != nil {
+			continue
+		}
+
+		fields := make([]string, 0, len(kubeSecret.Data))
+		for field := range kubeSecret.Data {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		listings = append(listings, secrets.SecretListing{Key: kubeSecret.Name, Fields: fields})
+	}
+
+	if r.Key != "" && len(listings) == 0 {
+		return nil, secrets.ErrPolicy.With("entity not allowed to access secret")
+	}
+
+	return listings, nil
+}
+
+// listSecrets lists the Secrets of the policy namespace, as the impersonated user if any.
+func (s *kubeSecretsServer) listSecrets(ctx context.Context, policy *kubeApplicationPolicy) ([]corev1.Secret, error) {
+	kubeClient, err := s.clients.Get(policy.Impersonate)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracer.Start(ctx, "kubernetes list secrets", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("k8s.namespace.name", policy.Namespace),
+		attribute.String("k8s.impersonate", policy.Impersonate),
+	))
+	defer span.End()
+
+	start := time.Now()
+	secretList, err := kubeClient.CoreV1().Secrets(policy.Namespace).List(ctx, metav1.ListOptions{})
+	s.metrics.ObserveUpstream("list_secrets", err, time.Since(start))
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	sort.Slice(secretList.Items, func(i, j int) bool {
+		return secretList.Items[i].Name < secretList.Items[j].Name
+	})
+
+	return secretList.Items, nil
+}
+
+// parseLabels parses comma separated 'key=value' pairs.
+func parseLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, label := range splitList(value) {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("label '%s' isn't key=value", label)
+		}
+		labels[k] = v
+	}
+
+	return labels, nil
+}
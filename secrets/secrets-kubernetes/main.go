@@ -3,36 +3,67 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nkeys"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
 	ServiceName = "kube"
+
+	// EncodingAnnotation forces how Secret values are returned to components: 'string' or 'binary'.
+	// When absent, values that aren't valid UTF-8 are returned as binary secrets.
+	EncodingAnnotation = "secrets.wasmcloud.dev/encoding"
+	EncodingString     = "string"
+	EncodingBinary     = "binary"
+
+	// Comma separated lists restricting which components can read a Secret.
+	// See secrets.EntityRule for matching semantics.
+	AllowedComponentsAnnotation  = "secrets.wasmcloud.dev/allowed-components"
+	AllowedCallAliasesAnnotation = "secrets.wasmcloud.dev/allowed-call-aliases"
+	AllowedTagsAnnotation        = "secrets.wasmcloud.dev/allowed-tags"
 )
 
-type kubeSecretsServer struct{}
+type kubeSecretsServer struct {
+	clients  *kubeClientCache
+	informer *kubeSecretInformer
+	metrics  *kubeMetrics
+	events   *changeNotifier
+}
 
 type kubeApplicationPolicy struct {
 	Impersonate string `json:"impersonate"`
 	Namespace   string `json:"namespace"`
+
+	secrets.EntityRule
+	secrets.HostRule
 }
 
-func parseApplicationPolicy(r *secrets.Request) (*kubeApplicationPolicy, error) {
-	rawPolicy, err := r.Context.Application.PolicyProperties()
+func parseApplicationPolicy(reqCtx secrets.Context) (*kubeApplicationPolicy, error) {
+	rawPolicy, err := reqCtx.Application.PolicyProperties()
 	if err != nil {
 		return nil, err
 	}
@@ -42,10 +73,38 @@ func parseApplicationPolicy(r *secrets.Request) (*kubeApplicationPolicy, error)
 }
 
 func (s *kubeSecretsServer) Get(ctx context.Context, r *secrets.Request) (*secrets.SecretValue, error) {
-	policy, err := parseApplicationPolicy(r)
+	policy, err := authorizePolicy(r.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.get(ctx, r, policy, s.fetchSecret)
+}
+
+// secretFetcher reads a Secret for a policy, see kubeSecretsServer.fetchSecret.
+type secretFetcher func(ctx context.Context, policy *kubeApplicationPolicy, name string) (*corev1.Secret, error)
+
+// authorizePolicy parses the application policy and checks the host and entity against it.
+// It only depends on the request context, so batches check it once for all items.
+func authorizePolicy(reqCtx secrets.Context) (*kubeApplicationPolicy, error) {
+	policy, err := parseApplicationPolicy(reqCtx)
 	if err != nil {
 		return nil, secrets.ErrPolicy.With(err.Error())
 	}
+
+	if err := reqCtx.AuthorizeHost(policy.HostRule); err != nil {
+		return nil, err
+	}
+
+	if err := reqCtx.AuthorizeEntity(policy.EntityRule); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// get reads a field for a request allowed by authorizePolicy, checking the entity against the Secret annotations.
+func (s *kubeSecretsServer) get(ctx context.Context, r *secrets.Request, policy *kubeApplicationPolicy, fetch secretFetcher) (*secrets.SecretValue, error) {
 	slog.Info("Get", slog.String("application", r.Context.Application.Name), slog.String("impersonate", policy.Impersonate), slog.String("key", r.Key), slog.String("field", r.Field))
 
 	if r.Key == "" {
@@ -56,14 +115,27 @@ func (s *kubeSecretsServer) Get(ctx context.Context, r *secrets.Request) (*secre
 		return nil, secrets.ErrOther.With("missing secret key/field")
 	}
 
-	kubeClient, err := kubeClientWithImpersonation(policy.Impersonate)
-	if err != nil {
-		return nil, secrets.ErrUpstream.With(err.Error())
+	// pinned versions name an immutable Secret, unpinned responses report the ResourceVersion for information only
+	var (
+		kubeSecret *corev1.Secret
+		err        error
+	)
+	version := r.Version
+	if version != "" {
+		kubeSecret, err = fetchSecretVersion(ctx, fetch, policy, r.Key, version)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		kubeSecret, err = fetch(ctx, policy, r.Key)
+		if err != nil {
+			return nil, secrets.ErrUpstream.With(err.Error())
+		}
+		version = kubeSecret.ResourceVersion
 	}
 
-	kubeSecret, err := kubeClient.Secrets(policy.Namespace).Get(ctx, r.Key, metav1.GetOptions{})
-	if err != nil {
-		return nil, secrets.ErrUpstream.With(err.Error())
+	if err := r.Context.AuthorizeEntity(secretEntityRule(kubeSecret)); err != nil {
+		return nil, err
 	}
 
 	kubeEntryValue, ok := kubeSecret.Data[r.Field]
@@ -71,42 +143,256 @@ func (s *kubeSecretsServer) Get(ctx context.Context, r *secrets.Request) (*secre
 		return nil, secrets.ErrSecretNotFound
 	}
 
-	return &secrets.SecretValue{
-		StringSecret: string(kubeEntryValue),
-		Version:      kubeSecret.ResourceVersion,
-	}, nil
+	value, err := secretValue(kubeSecret, kubeEntryValue, version)
+	// pinned versions are immutable Secrets, they never change
+	if err == nil && r.Version == "" {
+		s.events.Track(policy.Impersonate, policy.Namespace, kubeSecret.Name)
+	}
+	return value, err
+}
+
+func secretEntityRule(kubeSecret *corev1.Secret) secrets.EntityRule {
+	return secrets.EntityRule{
+		PublicKeys:  splitList(kubeSecret.Annotations[AllowedComponentsAnnotation]),
+		CallAliases: splitList(kubeSecret.Annotations[AllowedCallAliasesAnnotation]),
+		Tags:        splitList(kubeSecret.Annotations[AllowedTagsAnnotation]),
+	}
 }
 
-func kubeClientWithImpersonation(role string) (clientcorev1.CoreV1Interface, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, nil).ClientConfig()
+// readRateLimitOverrides reads the JSON object of rate limits by JWT subject in 'path'.
+func readRateLimitOverrides(path string) (map[string]secrets.RateLimit, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if role != "" {
-		config.Impersonate.UserName = role
+	overrides := make(map[string]secrets.RateLimit)
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return overrides, nil
+}
+
+// newReplayStore tracks seen requests in the NATS KV 'bucket', or in memory when blank.
+func newReplayStore(nc *nats.Conn, bucket string, window time.Duration) (secrets.ReplayStore, error) {
+	if bucket == "" {
+		return secrets.NewMemoryReplayStore(), nil
 	}
 
-	kubeClientset, err := kubernetes.NewForConfig(config)
+	js, err := nc.JetStream()
 	if err != nil {
 		return nil, err
 	}
 
-	return kubeClientset.CoreV1(), nil
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "wasmCloud secrets replay protection",
+			TTL:         window,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureReplayBucketTTL(js, kv, window); err != nil {
+		return nil, err
+	}
+
+	return secrets.NewKeyValueReplayStore(kv), nil
+}
+
+// ensureReplayBucketTTL raises the TTL of an existing bucket shorter than 'window', which would forget requests before the window closes.
+func ensureReplayBucketTTL(js nats.JetStreamContext, kv nats.KeyValue, window time.Duration) error {
+	status, err := kv.Status()
+	if err != nil {
+		return err
+	}
+
+	// a zero TTL keeps requests forever
+	if ttl := status.TTL(); ttl == 0 || ttl >= window {
+		return nil
+	}
+
+	bucketStatus, ok := status.(*nats.KeyValueBucketStatus)
+	if !ok {
+		return fmt.Errorf("replay bucket '%s' TTL %s is shorter than the replay window", kv.Bucket(), status.TTL())
+	}
+
+	streamConfig := bucketStatus.StreamInfo().Config
+	streamConfig.MaxAge = window
+	if _, err := js.UpdateStream(&streamConfig); err != nil {
+		return fmt.Errorf("couldn't raise replay bucket '%s' TTL to %s: %w", kv.Bucket(), window, err)
+	}
+
+	slog.Warn("Raised replay bucket TTL to the replay window", slog.String("bucket", kv.Bucket()), slog.Duration("previous", status.TTL()), slog.Duration("ttl", window))
+	return nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func secretValue(kubeSecret *corev1.Secret, value []byte, version string) (*secrets.SecretValue, error) {
+	encoding := kubeSecret.Annotations[EncodingAnnotation]
+	if encoding == "" {
+		encoding = EncodingString
+		if !utf8.Valid(value) {
+			encoding = EncodingBinary
+		}
+	}
+
+	switch encoding {
+	case EncodingString:
+		return &secrets.SecretValue{
+			StringSecret: string(value),
+			Version:      version,
+		}, nil
+	case EncodingBinary:
+		return &secrets.SecretValue{
+			BinarySecret: secrets.ByteArray(value),
+			Version:      version,
+		}, nil
+	default:
+		return nil, secrets.ErrOther.With(fmt.Sprintf("unknown encoding '%s'", encoding))
+	}
+}
+
+// fetchSecretVersion resolves a pinned version from an immutable Secret named '<key>-v<version>'.
+// Versions are never compared to ResourceVersions. Mutable Secrets are ignored, as their contents could change under the pinned version.
+func fetchSecretVersion(ctx context.Context, fetch secretFetcher, policy *kubeApplicationPolicy, name string, version string) (*corev1.Secret, error) {
+	kubeSecret, err := fetch(ctx, policy, versionedSecretName(name, version))
+	if apierrors.IsNotFound(err) {
+		return nil, secrets.ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, secrets.ErrUpstream.With(err.Error())
+	}
+
+	if kubeSecret.Immutable == nil || !*kubeSecret.Immutable {
+		return nil, secrets.ErrVersionNotFound
+	}
+
+	return kubeSecret, nil
+}
+
+func versionedSecretName(name string, version string) string {
+	return fmt.Sprintf("%s-v%s", name, version)
+}
+
+// fetchSecret reads a Secret from the informer cache when possible, falling back to the API server.
+// Impersonated requests always go to the API server so RBAC is evaluated for the impersonated user.
+func (s *kubeSecretsServer) fetchSecret(ctx context.Context, policy *kubeApplicationPolicy, name string) (*corev1.Secret, error) {
+	if s.informer != nil && policy.Impersonate == "" {
+		if kubeSecret, ok := s.informer.Lookup(policy.Namespace, name); ok {
+			trace.SpanFromContext(ctx).AddEvent("secret cache hit")
+			return kubeSecret, nil
+		}
+	}
+
+	kubeClient, err := s.clients.Get(policy.Impersonate)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracer.Start(ctx, "kubernetes get secret", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("k8s.namespace.name", policy.Namespace),
+		attribute.String("k8s.secret.name", name),
+		attribute.String("k8s.impersonate", policy.Impersonate),
+	))
+	defer span.End()
+
+	start := time.Now()
+	kubeSecret, err := kubeClient.CoreV1().Secrets(policy.Namespace).Get(ctx, name, metav1.GetOptions{})
+	s.metrics.ObserveUpstream("get_secret", err, time.Since(start))
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return kubeSecret, err
+}
+
+func kubeClientConfig() (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, nil).ClientConfig()
 }
 
 func main() {
 	var (
-		natsURL            = flag.String("nats-url", nats.DefaultURL, "Nats URL")
-		natsCreds          = flag.String("nats-creds", "", "NATS credentials file path.")
-		secretsBackendSeed = flag.String("backend-seed", "", "NKeys Curve Seed. Leave blank for ephemeral key, only recommended for development use")
+		natsURL             = flag.String("nats-url", nats.DefaultURL, "Nats URL")
+		natsCreds           = flag.String("nats-creds", "", "NATS credentials file path.")
+		secretsBackendSeed  = flag.String("backend-seed", "", "NKeys Curve Seed. Leave blank for ephemeral key, only recommended for development use")
+		retiredBackendSeeds = flag.String("retired-backend-seeds", "", "Comma separated NKeys Curve Seeds still accepted for decryption after a rotation of --backend-seed")
+		backendSeedFile     = flag.String("backend-seed-file", "", "File with the NKeys Curve Seed. Additional seeds, one per line, are retired keys. Watched for rotation")
+		backendSeedSecret   = flag.String("backend-seed-secret", "", "Kubernetes Secret field holding the NKeys Curve Seed, as 'namespace/name:key'. Same format as --backend-seed-file. Watched for rotation")
+		retiredKeyRetention = flag.Duration("retired-key-retention", DefaultRetiredKeyRetention, "How long a rotated out xkey keeps decrypting requests. Keep it above the time hosts cache the backend xkey")
+		clientCacheSize     = flag.Int("client-cache-size", DefaultClientCacheSize, "Maximum number of Kubernetes clients cached by impersonated user")
+		clientCacheTTL      = flag.Duration("client-cache-ttl", DefaultClientCacheTTL, "Evict cached Kubernetes clients after being idle for this long. Zero disables expiration")
+		informerEnabled     = flag.Bool("informer", false, "Serve non-impersonated reads from a watch-driven Secret cache")
+		informerNamespaces  = flag.String("informer-namespaces", "", "Comma separated namespaces to cache Secrets from. Leave blank for all namespaces")
+		informerSelector    = flag.String("informer-label-selector", "", "Only cache Secrets matching this label selector")
+		informerResync      = flag.Duration("informer-resync", 10*time.Minute, "Secret cache resync period")
+		entityIssuers       = flag.String("trusted-entity-issuers", "", "Comma separated account/operator public keys allowed to sign component & provider JWTs. Leave blank to trust any issuer")
+		hostIssuers         = flag.String("trusted-host-issuers", "", "Comma separated account/operator/cluster public keys allowed to sign host JWTs. Leave blank to trust any issuer")
+		jwtClockSkew        = flag.Duration("jwt-clock-skew", 30*time.Second, "Tolerated clock difference when checking JWT exp/nbf/iat claims")
+		jwtRequireExp       = flag.Bool("jwt-require-expiration", false, "Reject entity and host JWTs without an expiration claim")
+		tracingEnabled      = flag.Bool("tracing", false, "Export OpenTelemetry traces over OTLP/HTTP. Configure with OTEL_EXPORTER_OTLP_* environment variables")
+		otlpEndpoint        = flag.String("otlp-endpoint", "", "OTLP/HTTP traces endpoint URL, e.g. 'http://localhost:4318/v1/traces'. Overrides OTEL_EXPORTER_OTLP_* variables")
+		concurrency         = flag.Int("concurrency", 0, "Number of requests processed in parallel. Zero processes requests one at a time")
+		queueSize           = flag.Int("queue-size", secrets.DefaultQueueSize, "Maximum number of requests waiting for a worker when using --concurrency")
+		maxBatchSize        = flag.Int("max-batch-size", secrets.DefaultMaxBatchSize, "Maximum number of secrets fetched by a single 'batch_get' request")
+		requestTimeout      = flag.Duration("request-timeout", 5*time.Second, "Fail requests with a 'Timeout' error when they take longer than this, queueing included. Zero disables the deadline")
+		hostRateLimit       = flag.Float64("host-rate-limit", 0, "Requests per second allowed per host, each batch item counting as one. Zero disables the limit")
+		hostRateBurst       = flag.Int("host-rate-burst", 0, "Host request bursts. Zero uses the rate rounded up")
+		entityRateLimit     = flag.Float64("entity-rate-limit", 0, "Requests per second allowed per component or provider, each batch item counting as one. Zero disables the limit")
+		entityRateBurst     = flag.Int("entity-rate-burst", 0, "Component or provider request bursts. Zero uses the rate rounded up")
+		rateLimitOverrides  = flag.String("rate-limit-overrides-file", "", "JSON file mapping host, component or provider public keys to their own rate limit, e.g. '{\"N...\": {\"rate\": 50, \"burst\": 100}}'")
+		replayWindow        = flag.Duration("replay-window", 0, "Reject requests replayed within this window. Zero disables replay protection")
+		replayBucket        = flag.String("replay-kv-bucket", "", "NATS KV bucket shared by replicas to track seen requests, created if missing. Leave blank to track them in memory")
+		requireTimestamp    = flag.Bool("require-request-timestamp", false, "Reject requests without a timestamp. Current wasmCloud hosts don't send one, so all their requests are rejected. Requires --replay-window")
+		listEnabled         = flag.Bool("list", false, "Enable the 'list' operation, returning the secrets and fields, never values, an application policy can access")
+		listComponents      = flag.String("list-allowed-components", "", "Comma separated component or provider public keys allowed to list secrets. See --list")
+		listCallAliases     = flag.String("list-allowed-call-aliases", "", "Comma separated component call aliases allowed to list secrets. See --list")
+		listTags            = flag.String("list-allowed-tags", "", "Comma separated component tags allowed to list secrets. See --list")
+		listHostLabels      = flag.String("list-allowed-host-labels", "", "Comma separated 'key=value' labels hosts must carry to list secrets. See --list")
+		changeEvents        = flag.Bool("change-events", false, "Watch served Secrets and publish change events, without values, on 'wasmcloud.secrets.events.v1alpha1.kube'")
+		maxEventSecrets     = flag.Int("change-events-max-secrets", DefaultMaxTrackedSecrets, "Maximum number of served Secrets watched for change events")
+		eventHashKeyFile    = flag.String("change-events-hash-key-file", "", "File with the key of change event field hashes, shared by replicas. Leave blank for a random key, changing on restart")
+		auditLogPath        = flag.String("audit-log", "", "Write secret access audit events as JSON lines to 'stdout' or a file path")
+		auditSubject        = flag.String("audit-subject", "", "Publish secret access audit events to this NATS subject")
+		httpAddr            = flag.String("http-addr", "", "Address to serve Prometheus metrics and health probes on, e.g. ':8080'. Leave blank to disable")
 	)
 	flag.Parse()
 
 	slog.Info("Starting", slog.String("nats-url", *natsURL))
 
-	s := &kubeSecretsServer{}
+	shutdownTracing := func(context.Context) error { return nil }
+	if *tracingEnabled {
+		var err error
+		shutdownTracing, err = setupTracing(context.Background(), *otlpEndpoint)
+		if err != nil {
+			slog.Error("Couldn't setup tracing", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	kubeConfig, err := kubeClientConfig()
+	if err != nil {
+		slog.Error("Couldn't load kubernetes config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	s := &kubeSecretsServer{
+		clients: newKubeClientCache(kubeConfig, *clientCacheSize, *clientCacheTTL),
+	}
 
 	natsConnectOps := []nats.Option{}
 	if *natsCreds != "" {
@@ -119,37 +405,203 @@ func main() {
 		os.Exit(1)
 	}
 
+	audit, err := newAuditLog(nc, *auditLogPath, *auditSubject)
+	if err != nil {
+		slog.Error("Couldn't setup audit log", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	errorCallback := func(_ *nats.Msg, err error) {
 		slog.Error("server error", slog.Any("error", err))
 	}
 
-	var secretsBackendKey nkeys.KeyPair
-
-	if *secretsBackendSeed != "" {
-		secretsBackendKey, err = nkeys.FromSeed([]byte(*secretsBackendSeed))
-	} else {
+	var keySource serverKeySource
+	switch {
+	case *backendSeedFile != "" && *backendSeedSecret != "":
+		slog.Error("Couldn't setup XKey", slog.String("error", "--backend-seed-file and --backend-seed-secret are mutually exclusive"))
+		os.Exit(1)
+	case *backendSeedFile != "":
+		keySource = &fileKeySource{path: *backendSeedFile}
+	case *backendSeedSecret != "":
+		namespace, name, field, err := parseSecretRef(*backendSeedSecret)
+		if err != nil {
+			slog.Error("Couldn't setup XKey", slog.Any("error", err))
+			os.Exit(1)
+		}
+		kubeClient, err := s.clients.Get("")
+		if err != nil {
+			slog.Error("Couldn't setup kubernetes client", slog.Any("error", err))
+			os.Exit(1)
+		}
+		keySource = &secretKeySource{client: kubeClient, namespace: namespace, name: name, field: field}
+	case *secretsBackendSeed != "":
+		keySource = &seedKeySource{seeds: append([]string{*secretsBackendSeed}, splitList(*retiredBackendSeeds)...)}
+	default:
 		slog.Info("Creating ephemeral curve keys. DO NOT USE THIS IN PRODUCTION.")
-		secretsBackendKey, err = nkeys.CreateCurveKeys()
+		ephemeralKey, err := nkeys.CreateCurveKeys()
+		if err != nil {
+			slog.Error("Couldn't setup XKey", slog.Any("error", err))
+			os.Exit(1)
+		}
+		ephemeralSeed, err := ephemeralKey.Seed()
+		if err != nil {
+			slog.Error("Couldn't setup XKey", slog.Any("error", err))
+			os.Exit(1)
+		}
+		keySource = &seedKeySource{seeds: []string{string(ephemeralSeed)}}
 	}
+
+	keys := newKeyRotator(keySource, *retiredKeyRetention)
+	primaryKey, retiredKeys, _, err := keys.Keys(context.Background())
 	if err != nil {
 		slog.Error("Couldn't setup XKey", slog.Any("error", err))
 		os.Exit(1)
 	}
 
-	secretsServer, err := secrets.NewServer(ServiceName,
-		nc,
-		s,
-		secrets.WithKeyPair(secretsBackendKey),
+	serverOpts := []secrets.ServerOption{
+		secrets.WithKeyPair(primaryKey),
+		secrets.WithRetiredKeys(retiredKeys...),
 		secrets.WithErrorCallback(errorCallback),
-	)
+		secrets.WithTrustedEntityIssuers(splitList(*entityIssuers)...),
+		secrets.WithTrustedHostIssuers(splitList(*hostIssuers)...),
+		secrets.WithClockSkew(*jwtClockSkew),
+		secrets.WithRequestTimeout(*requestTimeout),
+		secrets.WithMaxBatchSize(*maxBatchSize),
+		secrets.WithHostRateLimit(secrets.RateLimit{Rate: *hostRateLimit, Burst: *hostRateBurst}),
+		secrets.WithEntityRateLimit(secrets.RateLimit{Rate: *entityRateLimit, Burst: *entityRateBurst}),
+	}
+	if *jwtRequireExp {
+		serverOpts = append(serverOpts, secrets.WithRequireExpiration())
+	}
+
+	if *rateLimitOverrides != "" {
+		overrides, err := readRateLimitOverrides(*rateLimitOverrides)
+		if err != nil {
+			slog.Error("Couldn't read rate limit overrides", slog.Any("error", err))
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, secrets.WithRateLimitOverrides(overrides))
+	}
+
+	if audit != nil {
+		serverOpts = append(serverOpts, secrets.WithAuditCallback(audit.Record))
+	}
+
+	if *replayWindow > 0 {
+		replayStore, err := newReplayStore(nc, *replayBucket, *replayWindow)
+		if err != nil {
+			slog.Error("Couldn't setup replay protection", slog.Any("error", err))
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, secrets.WithReplayProtection(replayStore, *replayWindow))
+	}
+	if *requireTimestamp {
+		serverOpts = append(serverOpts, secrets.WithRequireRequestTimestamp())
+	}
+
+	if *listEnabled {
+		hostLabels, err := parseLabels(*listHostLabels)
+		if err != nil {
+			slog.Error("Couldn't setup listing", slog.Any("error", err))
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, secrets.WithList(
+			secrets.EntityRule{
+				PublicKeys:  splitList(*listComponents),
+				CallAliases: splitList(*listCallAliases),
+				Tags:        splitList(*listTags),
+			},
+			secrets.HostRule{Labels: hostLabels},
+		))
+	}
+
+	if *concurrency > 0 {
+		serverOpts = append(serverOpts, secrets.WithConcurrency(*concurrency), secrets.WithQueueSize(*queueSize))
+	}
+
+	if *httpAddr != "" {
+		s.metrics = newKubeMetrics()
+		serverOpts = append(serverOpts, secrets.WithRequestCallback(s.metrics.ObserveRequest))
+	}
+
+	secretsServer, err := secrets.NewServer(ServiceName, nc, s, serverOpts...)
 	if err != nil {
 		slog.Error("Couldn't setup secrets server", slog.Any("error", err))
 		os.Exit(1)
 	}
 
-	mainCtx, mainCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
+	mainCtx, mainCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
 	defer mainCancel()
 
+	if err := keys.Watch(mainCtx, secretsServer); err != nil {
+		slog.Error("Couldn't watch xkeys", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	var httpServer *http.Server
+	if *httpAddr != "" {
+		s.metrics.ObserveQueue(secretsServer)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{}))
+		mux.Handle("/healthz", probeHandler(5*time.Second, func(context.Context) error {
+			return secretsServer.Live()
+		}))
+		mux.Handle("/readyz", probeHandler(5*time.Second, func(context.Context) error {
+			return secretsServer.Ready()
+		}))
+
+		httpServer = &http.Server{
+			Addr:              *httpAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			slog.Info("Serving metrics & health probes", slog.String("http-addr", *httpAddr))
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Couldn't serve http", slog.Any("error", err))
+				mainCancel()
+			}
+		}()
+	}
+
+	if *informerEnabled {
+		kubeClient, err := s.clients.Get("")
+		if err != nil {
+			slog.Error("Couldn't setup kubernetes client", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		namespaces := splitList(*informerNamespaces)
+		s.informer = newKubeSecretInformer(kubeClient, namespaces, *informerSelector, *informerResync)
+
+		slog.Info("Syncing secret cache", slog.Any("namespaces", namespaces), slog.String("label-selector", *informerSelector))
+		if err := s.informer.Start(mainCtx); err != nil {
+			slog.Error("Couldn't sync secret cache", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if *changeEvents {
+		hashKey, err := readEventHashKey(*eventHashKeyFile)
+		if err != nil {
+			slog.Error("Couldn't setup change events", slog.Any("error", err))
+			os.Exit(1)
+		}
+		if *eventHashKeyFile == "" {
+			slog.Warn("Change event field hashes use a random key, they differ between replicas and restarts")
+		}
+
+		eventsSubject := secrets.SubjectMapper{
+			Prefix:      secrets.DefaultSecretsBusPrefix,
+			Version:     secrets.DefaultSecretsProtocolVersion,
+			ServiceName: ServiceName,
+		}.EventsSubject()
+		s.events = newChangeNotifier(mainCtx, s.clients.Get, nc, eventsSubject, hashKey, *maxEventSecrets)
+		slog.Info("Publishing change events", slog.String("subject", eventsSubject))
+	}
+
 	if err := secretsServer.Run(); err != nil {
 		slog.Error("Couldn't setup secrets protocol server", slog.Any("error", err))
 		os.Exit(1)
@@ -165,6 +617,25 @@ func main() {
 		} else {
 			slog.Info("Drained all messages")
 		}
+		if s.informer != nil {
+			s.informer.Shutdown()
+		}
+		if s.events != nil {
+			s.events.Shutdown()
+		}
+		if audit != nil {
+			if err := audit.Close(); err != nil {
+				slog.Error("Couldn't close audit log", slog.Any("error", err))
+			}
+		}
+		if httpServer != nil {
+			if err := httpServer.Shutdown(context.Background()); err != nil {
+				slog.Error("Couldn't shutdown http server", slog.Any("error", err))
+			}
+		}
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Couldn't flush traces", slog.Any("error", err))
+		}
 		wg.Done()
 	}()
 
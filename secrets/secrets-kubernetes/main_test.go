@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestFetchSecretVersion(t *testing.T) {
+	immutable := true
+	mutable := false
+
+	current := kubeSecretForTest("app", map[string]string{"password": "current"})
+	current.ResourceVersion = "7"
+	pinned := kubeSecretForTest("app-v2", map[string]string{"password": "pinned"})
+	pinned.Immutable = &immutable
+	unpinnable := kubeSecretForTest("app-v3", map[string]string{"password": "mutable"})
+	unpinnable.Immutable = &mutable
+	implicit := kubeSecretForTest("app-v4", map[string]string{"password": "mutable"})
+
+	client := fake.NewSimpleClientset(current, pinned, unpinnable, implicit)
+	client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == "app-v5" {
+			return true, nil, fmt.Errorf("connection refused")
+		}
+		return false, nil, nil
+	})
+
+	s := &kubeSecretsServer{clients: clientCacheForTest(map[string]kubernetes.Interface{"": client})}
+	policy := &kubeApplicationPolicy{Namespace: "default"}
+
+	tests := map[string]struct {
+		version string
+		want    string
+		err     *secrets.ResponseError
+	}{
+		"immutable": {
+			version: "2",
+			want:    "pinned",
+		},
+		"mutable": {
+			version: "3",
+			err:     secrets.ErrVersionNotFound,
+		},
+		"immutableUnset": {
+			version: "4",
+			err:     secrets.ErrVersionNotFound,
+		},
+		"missing": {
+			version: "1",
+			err:     secrets.ErrVersionNotFound,
+		},
+		// versions name Secrets, they are never compared to ResourceVersions
+		"resourceVersion": {
+			version: "7",
+			err:     secrets.ErrVersionNotFound,
+		},
+		"upstreamError": {
+			version: "5",
+			err:     secrets.ErrUpstream,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			kubeSecret, err := fetchSecretVersion(context.Background(), s.fetchSecret, policy, "app", test.version)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("want %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want, got := test.want, string(kubeSecret.Data["password"]); want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestGetTracksServedSecrets(t *testing.T) {
+	immutable := true
+	pinned := kubeSecretForTest("app-v2", map[string]string{"password": "pinned"})
+	pinned.Immutable = &immutable
+
+	client := fake.NewSimpleClientset(kubeSecretForTest("app", map[string]string{"password": "current"}), pinned)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := newChangeNotifier(ctx, func(string) (kubernetes.Interface, error) { return client, nil }, nil, "events", []byte("key"), 0)
+	t.Cleanup(func() {
+		cancel()
+		events.Shutdown()
+	})
+
+	s := &kubeSecretsServer{
+		clients: clientCacheForTest(map[string]kubernetes.Interface{"": client}),
+		events:  events,
+	}
+
+	reqCtx := contextForTest(t, `{"namespace":"default"}`)
+	policy, err := authorizePolicy(reqCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pinned versions never change, they aren't watched
+	if _, err := s.get(ctx, &secrets.Request{Key: "app", Field: "password", Version: "2", Context: reqCtx}, policy, s.fetchSecret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.get(ctx, &secrets.Request{Key: "app", Field: "password", Context: reqCtx}, policy, s.fetchSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	events.Lock()
+	defer events.Unlock()
+	if _, ok := events.tracked[trackedSecretKey("", "default", "app")]; !ok || len(events.tracked) != 1 {
+		t.Errorf("want only default/app watched, got %v secrets", len(events.tracked))
+	}
+}
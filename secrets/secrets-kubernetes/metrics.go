@@ -0,0 +1,115 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+const (
+	metricsNamespace = "wasmcloud_secrets"
+	resultOK         = "ok"
+)
+
+type kubeMetrics struct {
+	registry *prometheus.Registry
+
+	requests           *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	decryptionFailures prometheus.Counter
+	upstreamDuration   *prometheus.HistogramVec
+}
+
+func newKubeMetrics() *kubeMetrics {
+	m := &kubeMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Secrets protocol requests by operation and result.",
+		}, []string{"operation", "result"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling secrets protocol requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		decryptionFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "decryption_failures_total",
+			Help:      "Requests that couldn't be decrypted with the server xkey.",
+		}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "kubernetes",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of Kubernetes API calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.decryptionFailures,
+		m.upstreamDuration,
+	)
+
+	return m
+}
+
+// ObserveRequest is a secrets.ServerRequestCallback.
+func (m *kubeMetrics) ObserveRequest(_ *nats.Msg, operation string, err *secrets.ResponseError, elapsed time.Duration) {
+	result := resultOK
+	if err != nil {
+		result = err.Kind
+	}
+
+	m.requests.WithLabelValues(operation, result).Inc()
+	m.requestDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+
+	if err != nil && err.Tip == secrets.ErrDecryption.Tip {
+		m.decryptionFailures.Inc()
+	}
+}
+
+// ObserveUpstream records the latency of a Kubernetes API call. Safe to call on a nil receiver.
+func (m *kubeMetrics) ObserveUpstream(operation string, err error, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+
+	result := resultOK
+	if err != nil {
+		result = "error"
+	}
+
+	m.upstreamDuration.WithLabelValues(operation, result).Observe(elapsed.Seconds())
+}
+
+// ObserveQueue exposes the request queue of the secrets server.
+func (m *kubeMetrics) ObserveQueue(server *secrets.Server) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "queue_pending",
+			Help:      "Requests waiting for a worker.",
+		}, func() float64 {
+			pending, _ := server.QueueStats()
+			return float64(pending)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "queue_capacity",
+			Help:      "Maximum number of requests waiting for a worker.",
+		}, func() float64 {
+			_, capacity := server.QueueStats()
+			return float64(capacity)
+		}),
+	)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+)
+
+func TestObserveRequest(t *testing.T) {
+	m := newKubeMetrics()
+
+	m.ObserveRequest(nil, "get", nil, time.Millisecond)
+	m.ObserveRequest(nil, "get", secrets.ErrServerBusy, time.Millisecond)
+	m.ObserveRequest(nil, "get", secrets.ErrRateLimited, time.Millisecond)
+	m.ObserveRequest(nil, "get", secrets.ErrOther.With("boom"), time.Millisecond)
+	m.ObserveRequest(nil, "get", secrets.ErrDecryption, time.Millisecond)
+
+	// errors sharing the Other tip are told apart by their kind
+	for result, want := range map[string]float64{"ok": 1, "ServerBusy": 1, "RateLimited": 1, "Other": 1, "DecryptionError": 1} {
+		if got := testutil.ToFloat64(m.requests.WithLabelValues("get", result)); want != got {
+			t.Errorf("%v: want %v, got %v", result, want, got)
+		}
+	}
+
+	if want, got := float64(1), testutil.ToFloat64(m.decryptionFailures); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"slices"
+)
+
+// EntityRule restricts which components or providers can access a secret.
+// An entity is allowed when it matches any of the listed public keys, call aliases or tags.
+// An empty rule allows every entity.
+type EntityRule struct {
+	PublicKeys  []string `json:"allowedComponents,omitempty"`
+	CallAliases []string `json:"allowedCallAliases,omitempty"`
+	Tags        []string `json:"allowedTags,omitempty"`
+}
+
+func (r EntityRule) IsEmpty() bool {
+	return len(r.PublicKeys) == 0 && len(r.CallAliases) == 0 && len(r.Tags) == 0
+}
+
+// Allows checks the entity's verified claims against the rule.
+func (r EntityRule) Allows(wasCap *WasCap, claims *ComponentClaims) bool {
+	if r.IsEmpty() {
+		return true
+	}
+
+	if wasCap != nil && wasCap.Subject != "" && slices.Contains(r.PublicKeys, wasCap.Subject) {
+		return true
+	}
+
+	if claims == nil {
+		return false
+	}
+
+	if claims.CallAlias != "" && slices.Contains(r.CallAliases, claims.CallAlias) {
+		return true
+	}
+
+	for _, tag := range claims.Tags {
+		if slices.Contains(r.Tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AuthorizeEntity verifies the request's entity JWT and checks it against all rules.
+// Every non-empty rule must allow the entity.
+func (ctx Context) AuthorizeEntity(rules ...EntityRule) *ResponseError {
+	wasCap, claims, err := ctx.EntityCapabilities()
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if !rule.Allows(wasCap, claims) {
+			return ErrPolicy.With("entity not allowed to access secret")
+		}
+	}
+
+	return nil
+}
+
+// HostRule restricts which hosts can receive a secret, based on their signed labels.
+// A host is allowed when it carries every listed label with the same value.
+// An empty rule allows every host.
+type HostRule struct {
+	Labels map[string]string `json:"allowedHostLabels,omitempty"`
+}
+
+func (r HostRule) IsEmpty() bool {
+	return len(r.Labels) == 0
+}
+
+// Allows checks the host's verified claims against the rule.
+func (r HostRule) Allows(claims *HostClaims) bool {
+	if r.IsEmpty() {
+		return true
+	}
+
+	if claims == nil {
+		return false
+	}
+
+	for k, v := range r.Labels {
+		if hostValue, ok := claims.Labels[k]; !ok || hostValue != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AuthorizeHost verifies the request's host JWT and checks it against all rules.
+// Every non-empty rule must allow the host.
+func (ctx Context) AuthorizeHost(rules ...HostRule) *ResponseError {
+	_, claims, err := ctx.HostCapabilities()
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if !rule.Allows(claims) {
+			return ErrPolicy.With("host not allowed to access secret")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package secrets
+
+import (
+	"encoding/json"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nkeys"
+)
+
+func accountKeyForTest(t *testing.T) nkeys.KeyPair {
+	t.Helper()
+
+	kp, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kp
+}
+
+// signedWasCapForTest signs a wasCap token with the issuer key.
+// The issuer claim is filled from the key when left blank.
+func signedWasCapForTest(t *testing.T, issuer nkeys.KeyPair, registered jwt.RegisteredClaims, claims interface{}) string {
+	t.Helper()
+
+	if registered.Issuer == "" {
+		issuerPubKey, err := issuer.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		registered.Issuer = issuerPubKey
+	}
+
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wasCap := &WasCap{
+		RegisteredClaims: registered,
+		Was:              rawClaims,
+		Revision:         3,
+	}
+
+	signed, err := jwt.NewWithClaims(SigningMethodEd25519, wasCap).SignedString(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestEntityRule(t *testing.T) {
+	wasCap := &WasCap{RegisteredClaims: jwt.RegisteredClaims{Subject: "MCOMPONENT"}}
+	claims := &ComponentClaims{
+		Name:      "http-component",
+		CallAlias: "frontend",
+		Tags:      []string{"team-a", "wasmcloud.com/experimental"},
+	}
+
+	tests := map[string]struct {
+		rule  EntityRule
+		allow bool
+	}{
+		"empty":          {rule: EntityRule{}, allow: true},
+		"publicKey":      {rule: EntityRule{PublicKeys: []string{"MOTHER", "MCOMPONENT"}}, allow: true},
+		"callAlias":      {rule: EntityRule{CallAliases: []string{"frontend"}}, allow: true},
+		"tag":            {rule: EntityRule{Tags: []string{"team-a"}}, allow: true},
+		"anyMatch":       {rule: EntityRule{PublicKeys: []string{"MOTHER"}, Tags: []string{"team-a"}}, allow: true},
+		"otherPublicKey": {rule: EntityRule{PublicKeys: []string{"MOTHER"}}, allow: false},
+		"otherCallAlias": {rule: EntityRule{CallAliases: []string{"backend"}}, allow: false},
+		"otherTag":       {rule: EntityRule{Tags: []string{"team-b"}}, allow: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if want, got := test.allow, test.rule.Allows(wasCap, claims); want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("BlankCallAliasClaim", func(t *testing.T) {
+		rule := EntityRule{CallAliases: []string{""}}
+		if rule.Allows(wasCap, &ComponentClaims{}) {
+			t.Error("blank call alias shouldn't match")
+		}
+	})
+}
+
+func TestAuthorizeEntity(t *testing.T) {
+	reqCtx := Context{
+		EntityJwt: signedWasCapForTest(t, accountKeyForTest(t), jwt.RegisteredClaims{Subject: "MCOMPONENT"}, &ComponentClaims{
+			Name: "http-component",
+			Tags: []string{"team-a"},
+		}),
+	}
+
+	if err := reqCtx.AuthorizeEntity(); err != nil {
+		t.Errorf("no rules should allow: %v", err)
+	}
+
+	if err := reqCtx.AuthorizeEntity(EntityRule{}, EntityRule{Tags: []string{"team-a"}}); err != nil {
+		t.Errorf("matching rules should allow: %v", err)
+	}
+
+	err := reqCtx.AuthorizeEntity(EntityRule{Tags: []string{"team-a"}}, EntityRule{PublicKeys: []string{"MOTHER"}})
+	if err == nil {
+		t.Fatal("every rule must allow")
+	}
+	if want, got := ErrPolicy.Error(), err.Error(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	badCtx := Context{EntityJwt: "garbage"}
+	err = badCtx.AuthorizeEntity()
+	if err == nil {
+		t.Fatal("invalid entity jwt should fail")
+	}
+	if want, got := ErrInvalidEntityJWT.Error(), err.Error(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestHostRule(t *testing.T) {
+	claims := &HostClaims{
+		Name:   "host",
+		Labels: map[string]string{"zone": "prod", "region": "us-east-1"},
+	}
+
+	tests := map[string]struct {
+		rule  HostRule
+		allow bool
+	}{
+		"empty":        {rule: HostRule{}, allow: true},
+		"single":       {rule: HostRule{Labels: map[string]string{"zone": "prod"}}, allow: true},
+		"all":          {rule: HostRule{Labels: map[string]string{"zone": "prod", "region": "us-east-1"}}, allow: true},
+		"otherValue":   {rule: HostRule{Labels: map[string]string{"zone": "dev"}}, allow: false},
+		"missingLabel": {rule: HostRule{Labels: map[string]string{"tier": "gold"}}, allow: false},
+		"partial":      {rule: HostRule{Labels: map[string]string{"zone": "prod", "region": "eu-west-1"}}, allow: false},
+		"blankValue":   {rule: HostRule{Labels: map[string]string{"tier": ""}}, allow: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if want, got := test.allow, test.rule.Allows(claims); want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizeHost(t *testing.T) {
+	reqCtx := Context{
+		HostJwt: signedWasCapForTest(t, accountKeyForTest(t), jwt.RegisteredClaims{Subject: "NHOST"}, &HostClaims{
+			Name:   "host",
+			Labels: map[string]string{"zone": "dev"},
+		}),
+	}
+
+	if err := reqCtx.AuthorizeHost(HostRule{Labels: map[string]string{"zone": "dev"}}); err != nil {
+		t.Errorf("matching labels should allow: %v", err)
+	}
+
+	err := reqCtx.AuthorizeHost(HostRule{Labels: map[string]string{"zone": "prod"}})
+	if err == nil {
+		t.Fatal("dev host shouldn't be allowed")
+	}
+	if want, got := ErrPolicy.Error(), err.Error(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,112 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// BatchItem identifies one secret of a BatchRequest.
+type BatchItem struct {
+	Key     string `json:"key"`
+	Field   string `json:"field"`
+	Version string `json:"version"`
+}
+
+// BatchRequest fetches several secrets in one round trip. The context is validated once for all items.
+type BatchRequest struct {
+	Items   []BatchItem `json:"items"`
+	Context Context     `json:"context"`
+	// Timestamp is when the request was sealed, in seconds since the epoch. Optional, see WithRequireRequestTimestamp.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+func (r *BatchRequest) envelope() (*Context, int64) {
+	return &r.Context, r.Timestamp
+}
+
+// Request returns item 'i' as a standalone Request, sharing the batch context.
+func (r BatchRequest) Request(i int) *Request {
+	return &Request{
+		Key:       r.Items[i].Key,
+		Field:     r.Items[i].Field,
+		Version:   r.Items[i].Version,
+		Context:   r.Context,
+		Timestamp: r.Timestamp,
+	}
+}
+
+func (r BatchRequest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&r)
+}
+
+func (r BatchRequest) String() string {
+	var b bytes.Buffer
+	_ = r.Write(&b)
+	return b.String()
+}
+
+// BatchResponse holds one Response per item, in order. Error is only set when the whole batch failed.
+type BatchResponse struct {
+	Results []Response     `json:"results,omitempty"`
+	Error   *ResponseError `json:"error,omitempty"`
+}
+
+func (r BatchResponse) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&r)
+}
+
+func (r BatchResponse) String() string {
+	var b bytes.Buffer
+	_ = r.Write(&b)
+	return b.String()
+}
+
+// BatchHandler is implemented by Handlers able to fetch several secrets at once, e.g. reading each upstream secret once.
+// Handlers that don't implement it get one Get call per item.
+type BatchHandler interface {
+	// GetBatch returns one Response per item, in order, with per-item failures in Response.Error.
+	// Returning an error fails the whole batch.
+	GetBatch(ctx context.Context, r *BatchRequest) ([]Response, error)
+}
+
+// getBatch calls the handler GetBatch, or Get for every item, giving up as soon as 'ctx' is done.
+// See callHandler for the returned wait function.
+func (s *Server) getBatch(ctx context.Context, req *BatchRequest) ([]Response, func(), error) {
+	if batchHandler, ok := s.handler.(BatchHandler); ok {
+		results, wait, err := callHandler(ctx, func(ctx context.Context) ([]Response, error) {
+			return batchHandler.GetBatch(ctx, req)
+		})
+		if err != nil {
+			return nil, wait, err
+		}
+
+		if len(results) != len(req.Items) {
+			return nil, wait, ErrUpstream.With(fmt.Sprintf("got %d results for %d items", len(results), len(req.Items)))
+		}
+
+		return results, wait, nil
+	}
+
+	results := make([]Response, len(req.Items))
+	for i := range req.Items {
+		// only the last call can still be running, earlier ones returned before the next started
+		value, wait, err := s.handle(ctx, req.Request(i))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, wait, contextError(ctx)
+			}
+			results[i].Error = handlerError(ctx, err)
+			continue
+		}
+		results[i].Secret = value
+	}
+
+	return results, func() {}, nil
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type testBatchHandler struct {
+	testHandler
+	batchFunc func(ctx context.Context, r *BatchRequest) ([]Response, error)
+}
+
+func (t *testBatchHandler) GetBatch(ctx context.Context, r *BatchRequest) ([]Response, error) {
+	return t.batchFunc(ctx, r)
+}
+
+// batchClientForTest runs a server for 'handler' and returns a client talking to it.
+func batchClientForTest(t *testing.T, handler Handler, opts ...ServerOption) *Client {
+	t.Helper()
+
+	nc := natsConnectionForTest(t)
+
+	server, err := NewServer("kube", nc, handler, append(opts, WithEphemeralKey())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	client, err := NewClient("kube", nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestServerBatchGet(t *testing.T) {
+	items := []BatchItem{
+		{Key: "a", Field: "password"},
+		{Key: "missing", Field: "password"},
+		{Key: "a", Field: "username"},
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		var calls atomic.Int32
+		handler := &testHandler{
+			getFunc: func(_ context.Context, r *Request) (*SecretValue, error) {
+				calls.Add(1)
+				if r.Key == "missing" {
+					return nil, ErrSecretNotFound
+				}
+				if r.Context.Application.Name != "appname" {
+					return nil, errors.New("missing batch context")
+				}
+				return &SecretValue{StringSecret: r.Key + "/" + r.Field}, nil
+			},
+		}
+
+		client := batchClientForTest(t, handler)
+
+		results, err := client.GetBatch(context.Background(), &BatchRequest{Items: items, Context: contextForTest()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, got := int32(3), calls.Load(); want != got {
+			t.Errorf("want %v calls, got %v", want, got)
+		}
+		if want, got := "a/password", results[0].Secret.StringSecret; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+		if results[1].Error == nil || results[1].Error.Tip != ErrSecretNotFound.Tip {
+			t.Errorf("want %v, got %v", ErrSecretNotFound, results[1].Error)
+		}
+		if want, got := "a/username", results[2].Secret.StringSecret; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	})
+
+	t.Run("BatchHandler", func(t *testing.T) {
+		handler := &testBatchHandler{
+			testHandler: testHandler{
+				getFunc: func(context.Context, *Request) (*SecretValue, error) {
+					return nil, errors.New("batches shouldn't call Get")
+				},
+			},
+			batchFunc: func(_ context.Context, r *BatchRequest) ([]Response, error) {
+				results := make([]Response, len(r.Items))
+				for i, item := range r.Items {
+					results[i].Secret = &SecretValue{StringSecret: "batch:" + item.Key}
+				}
+				return results, nil
+			},
+		}
+
+		client := batchClientForTest(t, handler)
+
+		results, err := client.GetBatch(context.Background(), &BatchRequest{Items: items, Context: contextForTest()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, item := range items {
+			if want, got := "batch:"+item.Key, results[i].Secret.StringSecret; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		}
+	})
+
+	t.Run("ResultCountMismatch", func(t *testing.T) {
+		handler := &testBatchHandler{
+			batchFunc: func(context.Context, *BatchRequest) ([]Response, error) {
+				return []Response{{Secret: &SecretValue{StringSecret: "one"}}}, nil
+			},
+		}
+
+		client := batchClientForTest(t, handler)
+
+		_, err := client.GetBatch(context.Background(), &BatchRequest{Items: items, Context: contextForTest()})
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) || respErr.Tip != ErrUpstream.Tip {
+			t.Errorf("want %v, got %v", ErrUpstream, err)
+		}
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		client := batchClientForTest(t, &testHandler{}, WithMaxBatchSize(2))
+
+		_, err := client.GetBatch(context.Background(), &BatchRequest{Items: items, Context: contextForTest()})
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) || respErr.Tip != ErrInvalidRequest.Tip {
+			t.Errorf("want %v, got %v", ErrInvalidRequest, err)
+		}
+	})
+
+	t.Run("InvalidContext", func(t *testing.T) {
+		client := batchClientForTest(t, &testHandler{})
+
+		_, err := client.GetBatch(context.Background(), &BatchRequest{Items: items})
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) || respErr.Tip != ErrInvalidEntityJWT.Tip {
+			t.Errorf("want %v, got %v", ErrInvalidEntityJWT, err)
+		}
+	})
+}
@@ -0,0 +1,309 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+const (
+	DefaultClientTimeout      = 5 * time.Second
+	DefaultClientRetryBackoff = 100 * time.Millisecond
+)
+
+// Client talks to a secrets backend over NATS, the way wasmCloud hosts do.
+type Client struct {
+	natsConn      *nats.Conn
+	key           nkeys.KeyPair
+	pubKey        string
+	subjectMapper SubjectMapper
+	timeout       time.Duration
+	retries       int
+	retryBackoff  time.Duration
+
+	serverKeyLock sync.Mutex
+	serverKey     string
+}
+
+type ClientOption func(*Client) error
+
+// WithClientKeyPair seals requests with 'kp' instead of an ephemeral curve key.
+func WithClientKeyPair(kp nkeys.KeyPair) ClientOption {
+	return func(c *Client) error {
+		c.key = kp
+		return nil
+	}
+}
+
+func WithClientSubjectMapper(m SubjectMapper) ClientOption {
+	return func(c *Client) error {
+		c.subjectMapper = m
+		return nil
+	}
+}
+
+// WithClientTimeout bounds each attempt. The context passed to Get bounds the whole call.
+func WithClientTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+		c.timeout = d
+		return nil
+	}
+}
+
+// WithRetries retries failed attempts up to 'n' times, doubling 'backoff' between attempts.
+// Only transient failures are retried: timeouts, missing responders, ErrServerBusy, ErrRateLimited and ErrTimeout.
+// ErrDecryption is retried once after fetching the server key again, in case it was rotated.
+func WithRetries(n int, backoff time.Duration) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("negative retries")
+		}
+		if backoff < 0 {
+			return fmt.Errorf("negative backoff")
+		}
+		c.retries = n
+		c.retryBackoff = backoff
+		return nil
+	}
+}
+
+// NewClient creates a client for the backend serving 'name'.
+func NewClient(name string, nc *nats.Conn, opts ...ClientOption) (*Client, error) {
+	client := &Client{
+		natsConn:     nc,
+		timeout:      DefaultClientTimeout,
+		retryBackoff: DefaultClientRetryBackoff,
+		subjectMapper: SubjectMapper{
+			Version:     DefaultSecretsProtocolVersion,
+			Prefix:      DefaultSecretsBusPrefix,
+			ServiceName: name,
+		},
+	}
+
+	for _, opt := range opts {
+		if err := opt(client); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidClientConfig, err)
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing name", ErrInvalidClientConfig)
+	}
+
+	if client.natsConn == nil {
+		return nil, fmt.Errorf("%w: nats connection", ErrInvalidClientConfig)
+	}
+
+	if client.key == nil {
+		var err error
+		client.key, err = nkeys.CreateCurveKeys()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidClientConfig, err)
+		}
+	}
+
+	var err error
+	client.pubKey, err = curvePublicKey(client.key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidClientConfig, err)
+	}
+
+	return client, nil
+}
+
+// ServerXkey returns the backend public xkey, fetching it on first use.
+func (c *Client) ServerXkey(ctx context.Context) (string, error) {
+	c.serverKeyLock.Lock()
+	defer c.serverKeyLock.Unlock()
+
+	if c.serverKey != "" {
+		return c.serverKey, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	reply, err := c.natsConn.RequestWithContext(ctx, c.subjectMapper.SecretsSubject()+".server_xkey", nil)
+	if err != nil {
+		return "", err
+	}
+
+	serverKey := string(reply.Data)
+	if !nkeys.IsValidPublicCurveKey(serverKey) {
+		return "", fmt.Errorf("invalid server xkey '%s'", serverKey)
+	}
+
+	c.serverKey = serverKey
+	return serverKey, nil
+}
+
+func (c *Client) forgetServerXkey() {
+	c.serverKeyLock.Lock()
+	defer c.serverKeyLock.Unlock()
+
+	c.serverKey = ""
+}
+
+// Get fetches a secret. Errors returned by the backend are *ResponseError.
+func (c *Client) Get(ctx context.Context, req *Request) (*SecretValue, error) {
+	var value *SecretValue
+	err := c.retry(ctx, func() error {
+		var resp Response
+		if err := c.request(ctx, "get", req, &resp); err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		if resp.Secret == nil {
+			return ErrSecretNotFound
+		}
+
+		value = resp.Secret
+		return nil
+	})
+
+	return value, err
+}
+
+// List lists the secrets the request policy can access, without their values. The backend must enable listing.
+// Errors returned by the backend are *ResponseError.
+func (c *Client) List(ctx context.Context, req *Request) ([]SecretListing, error) {
+	var listings []SecretListing
+	err := c.retry(ctx, func() error {
+		var resp ListResponse
+		if err := c.request(ctx, "list", req, &resp); err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		listings = resp.Secrets
+		return nil
+	})
+
+	return listings, err
+}
+
+// GetBatch fetches several secrets in one round trip, returning one Response per item, in order.
+// Per-item failures are in Response.Error. Errors failing the whole batch are *ResponseError.
+func (c *Client) GetBatch(ctx context.Context, req *BatchRequest) ([]Response, error) {
+	var results []Response
+	err := c.retry(ctx, func() error {
+		var resp BatchResponse
+		if err := c.request(ctx, "batch_get", req, &resp); err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		if len(resp.Results) != len(req.Items) {
+			return fmt.Errorf("got %d results for %d items", len(resp.Results), len(req.Items))
+		}
+
+		results = resp.Results
+		return nil
+	})
+
+	return results, err
+}
+
+// retry calls 'attempt' until it succeeds, following the WithRetries policy.
+func (c *Client) retry(ctx context.Context, attempt func() error) error {
+	backoff := c.retryBackoff
+	refreshed := false
+
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		var respErr *ResponseError
+		if errors.As(err, &respErr) && respErr.Tip == ErrDecryption.Tip && !refreshed {
+			// the server key may have been rotated, this doesn't count as a retry
+			refreshed = true
+			c.forgetServerXkey()
+			i--
+			continue
+		}
+
+		if i >= c.retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// request seals 'req' for 'operation' and decodes the reply into 'resp'.
+func (c *Client) request(ctx context.Context, operation string, req sealedRequest, resp interface{}) error {
+	serverKey, err := c.ServerXkey(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(c.subjectMapper.SecretsSubject() + "." + operation)
+	msg.Header.Set(WasmCloudHostXkey, c.pubKey)
+	msg.Data, err = c.key.Seal(data, serverKey)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	reply, err := c.natsConn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// protocol errors are sent in plain text, without a response key
+	payload := reply.Data
+	if responseKey := reply.Header.Get(WasmCloudResponseXkey); responseKey != "" {
+		payload, err = c.key.Open(reply.Data, responseKey)
+		if err != nil {
+			return fmt.Errorf("couldn't decrypt response: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(payload, resp); err != nil {
+		return fmt.Errorf("couldn't decode response: %w", err)
+	}
+
+	return nil
+}
+
+// retryable reports whether an attempt failed for a transient reason.
+func retryable(err error) bool {
+	if errors.Is(err, nats.ErrTimeout) || errors.Is(err, nats.ErrNoResponders) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	return errors.Is(err, ErrServerBusy) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout)
+}
@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	if _, err := NewClient("", nc); err == nil {
+		t.Error("name shouldn't be blank")
+	}
+
+	if _, err := NewClient("kube", nil); err == nil {
+		t.Error("nats connection shouldn't be nil")
+	}
+
+	if _, err := NewClient("kube", nc, WithRetries(-1, 0)); err == nil {
+		t.Error("retries shouldn't be negative")
+	}
+
+	if _, err := NewClient("kube", nc, WithClientKeyPair(keyPairForTest(t))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientGet(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	var failures atomic.Int32
+	handler := &testHandler{
+		getFunc: func(_ context.Context, r *Request) (*SecretValue, error) {
+			switch r.Key {
+			case "missing":
+				return nil, ErrSecretNotFound
+			case "busy":
+				if failures.Add(1) <= 2 {
+					return nil, ErrServerBusy
+				}
+			}
+			return &SecretValue{StringSecret: "value", Version: "1"}, nil
+		},
+	}
+
+	server, err := NewServer("kube", nc, handler, WithEphemeralKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	client, err := NewClient("kube", nc, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	serverKey, err := client.ServerXkey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := server.PublicKey(), serverKey; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	value, err := client.Get(ctx, &Request{Key: "secret", Field: "field", Context: contextForTest()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "value", value.StringSecret; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	_, err = client.Get(ctx, &Request{Key: "missing", Context: contextForTest()})
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.Tip != ErrSecretNotFound.Tip {
+		t.Errorf("want %v, got %v", ErrSecretNotFound, err)
+	}
+
+	if _, err := client.Get(ctx, &Request{Key: "busy", Context: contextForTest()}); err != nil {
+		t.Errorf("busy server should be retried: %v", err)
+	}
+	if want, got := int32(3), failures.Load(); want != got {
+		t.Errorf("want %v attempts, got %v", want, got)
+	}
+
+	// rotate the server key without retiring the old one, the client must fetch the new key
+	if err := server.SetKeys(keyPairForTest(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Get(ctx, &Request{Key: "secret", Context: contextForTest()}); err != nil {
+		t.Errorf("client should pick up the rotated key: %v", err)
+	}
+}
@@ -3,12 +3,16 @@ package secrets
 import (
 	"errors"
 	"fmt"
+	"slices"
 
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/nats-io/nkeys"
 )
 
-var ErrEd25519Verification = errors.New("ed25519: verification error")
+var (
+	ErrEd25519Verification = errors.New("ed25519: verification error")
+	ErrUntrustedIssuer     = errors.New("untrusted issuer")
+)
 
 // SigningMethodNats implements the Ed25519 family.
 type SigningMethodNats struct{}
@@ -62,3 +66,18 @@ func KeyPairFromIssuer() func(token *jwt.Token) (interface{}, error) {
 		return nkeys.FromPublicKey(iss)
 	}
 }
+
+// KeyPairFromTrustedIssuer behaves like KeyPairFromIssuer, but rejects issuers not present in 'trusted'.
+// An empty list trusts any issuer.
+func KeyPairFromTrustedIssuer(trusted []string) func(token *jwt.Token) (interface{}, error) {
+	return func(token *jwt.Token) (interface{}, error) {
+		iss, err := token.Claims.GetIssuer()
+		if err != nil {
+			return nil, err
+		}
+		if len(trusted) > 0 && !slices.Contains(trusted, iss) {
+			return nil, fmt.Errorf("%w: %s", ErrUntrustedIssuer, iss)
+		}
+		return nkeys.FromPublicKey(iss)
+	}
+}
@@ -0,0 +1,47 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	ChangeEventUpdated = "updated"
+	ChangeEventDeleted = "deleted"
+)
+
+// ChangeEvent notifies that a secret served by a backend changed, so hosts can fetch it again or restart components.
+// It never carries values.
+type ChangeEvent struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+	// Namespace scopes the key in backends that have one, e.g. the Kubernetes namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Version is the new version of the secret, blank once deleted.
+	Version string `json:"version,omitempty"`
+	// Fields maps every field to a keyed hash of its value. Hashes can't be reversed into values, but are comparable across events.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Changed lists the fields added, updated or removed by this change.
+	Changed []string  `json:"changed,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// EventsSubject is where backends publish ChangeEvents, e.g. 'wasmcloud.secrets.events.v1alpha1.kube'.
+// It is outside SecretWildcardSubject, so servers don't receive their own events as requests.
+func (s SubjectMapper) EventsSubject() string {
+	return fmt.Sprintf("%s.events.%s.%s", s.Prefix, s.Version, s.ServiceName)
+}
+
+// SubscribeEvents calls 'cb' for every ChangeEvent published by the backend. Malformed events are dropped.
+func (c *Client) SubscribeEvents(cb func(*ChangeEvent)) (*nats.Subscription, error) {
+	return c.natsConn.Subscribe(c.subjectMapper.EventsSubject(), func(msg *nats.Msg) {
+		event := &ChangeEvent{}
+		if err := json.Unmarshal(msg.Data, event); err != nil {
+			return
+		}
+		cb(event)
+	})
+}
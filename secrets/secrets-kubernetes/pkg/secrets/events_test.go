@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestChangeEvents(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	var requests atomic.Int32
+	onRequest := func(*nats.Msg, string, *ResponseError, time.Duration) {
+		requests.Add(1)
+	}
+
+	server, err := NewServer("kube", nc, &testHandler{}, WithEphemeralKey(), WithRequestCallback(onRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	client, err := NewClient("kube", nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan *ChangeEvent, 1)
+	sub, err := client.SubscribeEvents(func(event *ChangeEvent) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sub.Unsubscribe() })
+
+	if want, got := "wasmcloud.secrets.events.v1alpha1.kube", server.subjectMapper.EventsSubject(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	data, err := json.Marshal(&ChangeEvent{
+		Type:      ChangeEventUpdated,
+		Key:       "app-secrets",
+		Namespace: "default",
+		Version:   "42",
+		Fields:    map[string]string{"password": "hash"},
+		Changed:   []string{"password"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nc.Publish(server.subjectMapper.EventsSubject(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if want, got := "42", event.Version; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+		if want, got := "hash", event.Fields["password"]; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+	}
+
+	// make sure the event would have reached the server before checking it didn't
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nc.Request(server.subjectMapper.SecretsSubject()+".server_xkey", nil, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := int32(1), requests.Load(); want != got {
+		t.Errorf("events shouldn't reach the server, want %v requests, got %v", want, got)
+	}
+}
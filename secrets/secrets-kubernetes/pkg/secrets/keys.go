@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nkeys"
+)
+
+// WithRetiredKeys accepts requests sealed for previous server keys, so keys can be rotated without failing in-flight hosts.
+// Only the primary key ( see WithKeyPair ) is advertised on 'server_xkey'.
+func WithRetiredKeys(kps ...nkeys.KeyPair) ServerOption {
+	return func(s *Server) error {
+		s.retiredKeys = kps
+		return nil
+	}
+}
+
+// SetKeys replaces the server keys while it runs.
+// 'primary' is advertised on 'server_xkey', requests sealed for any of the 'retired' keys are still accepted.
+func (s *Server) SetKeys(primary nkeys.KeyPair, retired ...nkeys.KeyPair) error {
+	if primary == nil {
+		return errors.New("missing primary key")
+	}
+
+	pubKey, err := curvePublicKey(primary)
+	if err != nil {
+		return fmt.Errorf("primary key: %w", err)
+	}
+
+	for i, kp := range retired {
+		if _, err := curvePublicKey(kp); err != nil {
+			return fmt.Errorf("retired key %d: %w", i, err)
+		}
+	}
+
+	s.keysLock.Lock()
+	defer s.keysLock.Unlock()
+
+	s.key = primary
+	s.pubKey = pubKey
+	s.retiredKeys = retired
+
+	return nil
+}
+
+// PublicKey returns the primary server xkey.
+func (s *Server) PublicKey() string {
+	s.keysLock.RLock()
+	defer s.keysLock.RUnlock()
+
+	return s.pubKey
+}
+
+// open decrypts a request with the primary key, falling back to the retired ones.
+func (s *Server) open(data []byte, sender string) ([]byte, error) {
+	s.keysLock.RLock()
+	defer s.keysLock.RUnlock()
+
+	plain, err := s.key.Open(data, sender)
+	if err == nil {
+		return plain, nil
+	}
+
+	for _, kp := range s.retiredKeys {
+		if plain, retiredErr := kp.Open(data, sender); retiredErr == nil {
+			return plain, nil
+		}
+	}
+
+	return nil, err
+}
+
+func curvePublicKey(kp nkeys.KeyPair) (string, error) {
+	if kp == nil {
+		return "", errors.New("missing key pair")
+	}
+
+	pubKey, err := kp.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	if !nkeys.IsValidPublicCurveKey(pubKey) {
+		return "", errors.New("not a curve key")
+	}
+
+	return pubKey, nil
+}
@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+func TestServerKeyRotation(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	handler := &testHandler{
+		getFunc: func(context.Context, *Request) (*SecretValue, error) {
+			return &SecretValue{StringSecret: "value"}, nil
+		},
+	}
+
+	accountKey, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewServer("kube", nc, handler, WithKeyPair(accountKey)); err == nil {
+		t.Error("primary key should be a curve key")
+	}
+
+	if _, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithRetiredKeys(accountKey)); err == nil {
+		t.Error("retired keys should be curve keys")
+	}
+
+	oldKey, newKey := keyPairForTest(t), keyPairForTest(t)
+
+	server, err := NewServer("kube", nc, handler, WithKeyPair(oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	hostKey := keyPairForTest(t)
+	sealedForOldKey := getRequestForTest(t, server, hostKey, &Request{Key: "secret", Context: contextForTest()})
+
+	if err := server.SetKeys(newKey, oldKey); err != nil {
+		t.Fatal(err)
+	}
+
+	newPubKey, err := newKey.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xkeyReply, err := nc.Request(server.subjectMapper.SecretsSubject()+".server_xkey", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := newPubKey, string(xkeyReply.Data); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	reply, err := nc.RequestMsg(sealedForOldKey, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Header.Get(WasmCloudResponseXkey) == "" {
+		t.Errorf("retired key should still decrypt, got %s", reply.Data)
+	}
+
+	reply, err = nc.RequestMsg(getRequestForTest(t, server, hostKey, &Request{Key: "secret", Context: contextForTest()}), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Header.Get(WasmCloudResponseXkey) == "" {
+		t.Errorf("primary key should decrypt, got %s", reply.Data)
+	}
+
+	if err := server.SetKeys(newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err = nc.RequestMsg(sealedForOldKey, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := ErrDecryption.Tip, protocolErrorForTest(t, reply); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
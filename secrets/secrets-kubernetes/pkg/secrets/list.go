@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// Lister is implemented by Handlers able to enumerate the secrets a request can access.
+// Listings carry key and field names, never values.
+type Lister interface {
+	// List returns the secrets the request policy can access. When 'r.Key' isn't blank, only that secret is listed.
+	// 'r.Field' and 'r.Version' are ignored.
+	List(ctx context.Context, r *Request) ([]SecretListing, error)
+}
+
+// SecretListing describes a secret without its values.
+type SecretListing struct {
+	Key    string   `json:"key"`
+	Fields []string `json:"fields"`
+}
+
+type ListResponse struct {
+	Secrets []SecretListing `json:"secrets,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+func (r ListResponse) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&r)
+}
+
+func (r ListResponse) String() string {
+	var b bytes.Buffer
+	_ = r.Write(&b)
+	return b.String()
+}
+
+// authorizeList checks the request against the rules given to WithList.
+func (s *Server) authorizeList(ctx Context) *ResponseError {
+	if !s.listEnabled {
+		return ErrPolicy.With("listing is disabled")
+	}
+
+	if err := ctx.AuthorizeHost(s.listHostRule); err != nil {
+		return err
+	}
+
+	return ctx.AuthorizeEntity(s.listEntityRule)
+}
+
+// list calls the handler Lister, giving up as soon as 'ctx' is done even if the handler doesn't honor it.
+// See callHandler for the returned wait function.
+func (s *Server) list(ctx context.Context, req *Request) ([]SecretListing, func(), error) {
+	return callHandler(ctx, func(ctx context.Context) ([]SecretListing, error) {
+		return s.handler.(Lister).List(ctx, req)
+	})
+}
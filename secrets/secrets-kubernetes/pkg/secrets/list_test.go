@@ -0,0 +1,171 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+type testLister struct {
+	testHandler
+	listFunc func(ctx context.Context, r *Request) ([]SecretListing, error)
+}
+
+func (t *testLister) List(ctx context.Context, r *Request) ([]SecretListing, error) {
+	return t.listFunc(ctx, r)
+}
+
+func listerForTest() *testLister {
+	return &testLister{
+		listFunc: func(_ context.Context, r *Request) ([]SecretListing, error) {
+			if r.Key != "" {
+				return []SecretListing{{Key: r.Key, Fields: []string{"only"}}}, nil
+			}
+			return []SecretListing{{Key: "a", Fields: []string{"password", "username"}}, {Key: "b", Fields: []string{"token"}}}, nil
+		},
+	}
+}
+
+func TestServerList(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	if _, err := NewServer("kube", nc, &testHandler{}, WithEphemeralKey(), WithList(EntityRule{}, HostRule{})); err == nil {
+		t.Error("listing should require a Lister handler")
+	}
+
+	tests := map[string]struct {
+		opts      []ServerOption
+		key       string
+		want      []SecretListing
+		wantError string
+	}{
+		"disabled": {
+			wantError: ErrPolicy.Tip,
+		},
+		"enabled": {
+			opts: []ServerOption{WithList(EntityRule{}, HostRule{})},
+			want: []SecretListing{{Key: "a", Fields: []string{"password", "username"}}, {Key: "b", Fields: []string{"token"}}},
+		},
+		"key": {
+			opts: []ServerOption{WithList(EntityRule{}, HostRule{})},
+			key:  "c",
+			want: []SecretListing{{Key: "c", Fields: []string{"only"}}},
+		},
+		"entityAllowed": {
+			opts: []ServerOption{WithList(EntityRule{Tags: []string{"wasmcloud.com/experimental"}}, HostRule{Labels: map[string]string{"self_signed": "true"}})},
+			key:  "c",
+			want: []SecretListing{{Key: "c", Fields: []string{"only"}}},
+		},
+		"entityDenied": {
+			opts:      []ServerOption{WithList(EntityRule{Tags: []string{"operator"}}, HostRule{})},
+			wantError: ErrPolicy.Tip,
+		},
+		"hostDenied": {
+			opts:      []ServerOption{WithList(EntityRule{}, HostRule{Labels: map[string]string{"zone": "ops"}})},
+			wantError: ErrPolicy.Tip,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			nc := natsConnectionForTest(t)
+
+			server, err := NewServer("kube", nc, listerForTest(), append(tt.opts, WithEphemeralKey())...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.Run(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { server.Shutdown(false) })
+
+			client, err := NewClient("kube", nc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listings, err := client.List(context.Background(), &Request{Key: tt.key, Context: contextForTest()})
+			if tt.wantError != "" {
+				var respErr *ResponseError
+				if !errors.As(err, &respErr) || respErr.Tip != tt.wantError {
+					t.Fatalf("want %v, got %v", tt.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tt.want, listings) {
+				t.Errorf("want %v, got %v", tt.want, listings)
+			}
+		})
+	}
+}
+
+func TestServerListAuditCallback(t *testing.T) {
+	tests := map[string]struct {
+		opts []ServerOption
+		err  *ResponseError
+	}{
+		"allowed": {
+			opts: []ServerOption{WithList(EntityRule{}, HostRule{})},
+		},
+		"disabled": {
+			err: ErrPolicy,
+		},
+		"entityDenied": {
+			opts: []ServerOption{WithList(EntityRule{Tags: []string{"operator"}}, HostRule{})},
+			err:  ErrPolicy,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			nc := natsConnectionForTest(t)
+
+			type audit struct {
+				operation string
+				req       *Request
+				err       *ResponseError
+			}
+			audited := make(chan audit, 1)
+
+			opts := append(tt.opts, WithEphemeralKey(),
+				WithAuditCallback(func(_ *nats.Msg, operation string, req *Request, _ *SecretValue, err *ResponseError) {
+					audited <- audit{operation: operation, req: req, err: err}
+				}))
+			server, err := NewServer("kube", nc, listerForTest(), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.Run(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { server.Shutdown(false) })
+
+			client, err := NewClient("kube", nc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _ = client.List(context.Background(), &Request{Key: "c", Context: contextForTest()})
+
+			got := <-audited
+			if want, got := "list", got.operation; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if got.req == nil || got.req.Key != "c" {
+				t.Errorf("want request for %v, got %v", "c", got.req)
+			}
+			if want, got := tt.err, got.err; (want == nil) != (got == nil) || (want != nil && !errors.Is(got, want)) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// MultiServer serves several service names from one process, each with its own Handler.
+type MultiServer struct {
+	names   []string
+	servers map[string]*Server
+}
+
+// NewMultiServer creates a Server per service name in 'handlers'. 'opts' are applied to every Server.
+// Options setting per-server state, like WithEphemeralKey, give each service its own value.
+func NewMultiServer(nc *nats.Conn, handlers map[string]Handler, opts ...ServerOption) (*MultiServer, error) {
+	if len(handlers) == 0 {
+		return nil, fmt.Errorf("%w: missing handlers", ErrInvalidServerConfig)
+	}
+
+	m := &MultiServer{
+		servers: make(map[string]*Server, len(handlers)),
+	}
+
+	for name := range handlers {
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+
+	subjects := make(map[string]string, len(handlers))
+	for _, name := range m.names {
+		server, err := NewServer(name, nc, handlers[name], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		subject := server.subjectMapper.SecretsSubject()
+		if other, ok := subjects[subject]; ok {
+			return nil, fmt.Errorf("%w: %s and %s share subject %s", ErrInvalidServerConfig, other, name, subject)
+		}
+		subjects[subject] = name
+
+		m.servers[name] = server
+	}
+
+	return m, nil
+}
+
+// Server returns the Server for a service name, or nil.
+func (m *MultiServer) Server(name string) *Server {
+	return m.servers[name]
+}
+
+// Run subscribes every Server. If one fails, the others are shut down.
+func (m *MultiServer) Run() error {
+	for i, name := range m.names {
+		if err := m.servers[name].Run(); err != nil {
+			for _, started := range m.names[:i] {
+				_ = m.servers[started].Shutdown(false)
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// SetKeys replaces the keys of every Server, see Server.SetKeys.
+func (m *MultiServer) SetKeys(primary nkeys.KeyPair, retired ...nkeys.KeyPair) error {
+	for _, name := range m.names {
+		if err := m.servers[name].SetKeys(primary, retired...); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// QueueStats sums the queue stats of every Server.
+func (m *MultiServer) QueueStats() (pending int, capacity int) {
+	for _, server := range m.servers {
+		p, c := server.QueueStats()
+		pending += p
+		capacity += c
+	}
+
+	return pending, capacity
+}
+
+// Live reports whether every Server is live.
+func (m *MultiServer) Live() error {
+	for _, name := range m.names {
+		if err := m.servers[name].Live(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Ready reports whether every Server is ready.
+func (m *MultiServer) Ready() error {
+	for _, name := range m.names {
+		if err := m.servers[name].Ready(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Shutdown shuts every Server down, returning all errors.
+func (m *MultiServer) Shutdown(shouldDrain bool) error {
+	var errs []error
+	for _, name := range m.names {
+		if err := m.servers[name].Shutdown(shouldDrain); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
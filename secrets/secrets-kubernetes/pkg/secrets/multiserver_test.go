@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMultiServer(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	handlers := map[string]Handler{
+		"kube": namedHandlerForTest("kube"),
+		"file": namedHandlerForTest("file"),
+	}
+
+	if _, err := NewMultiServer(nc, nil, WithEphemeralKey()); err == nil {
+		t.Error("handlers shouldn't be empty")
+	}
+
+	sharedSubject := WithSubjectMapper(SubjectMapper{Prefix: DefaultSecretsBusPrefix, Version: DefaultSecretsProtocolVersion, ServiceName: "same"})
+	if _, err := NewMultiServer(nc, handlers, WithEphemeralKey(), sharedSubject); err == nil {
+		t.Error("services shouldn't share a subject")
+	}
+
+	servers, err := NewMultiServer(nc, handlers, WithEphemeralKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := servers.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = servers.Shutdown(false) })
+
+	if err := servers.Ready(); err != nil {
+		t.Error(err)
+	}
+
+	for name := range handlers {
+		server := servers.Server(name)
+		if server == nil {
+			t.Fatalf("missing %s server", name)
+		}
+
+		reply, err := nc.Request(server.subjectMapper.SecretsSubject()+".server_xkey", nil, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, got := server.PublicKey(), string(reply.Data); want != got {
+			t.Errorf("%s: want %v, got %v", name, want, got)
+		}
+	}
+
+	if servers.Server("vault") != nil {
+		t.Error("unknown service shouldn't have a server")
+	}
+}
@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+type poolJob struct {
+	ctx  context.Context
+	msg  *nats.Msg
+	done func()
+}
+
+// workerPool runs a fixed number of workers fed by a bounded queue.
+type workerPool struct {
+	jobs     chan poolJob
+	stop     chan struct{}
+	stopOnce sync.Once
+	inflight sync.WaitGroup
+	workers  sync.WaitGroup
+}
+
+func newWorkerPool(queueSize int) *workerPool {
+	return &workerPool{
+		jobs: make(chan poolJob, queueSize),
+		stop: make(chan struct{}),
+	}
+}
+
+// Start launches the workers.
+func (p *workerPool) Start(concurrency int, process func(context.Context, *nats.Msg)) {
+	p.workers.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer p.workers.Done()
+			for {
+				select {
+				case job := <-p.jobs:
+					process(job.ctx, job.msg)
+					if job.done != nil {
+						job.done()
+					}
+					p.inflight.Done()
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Submit queues a request, returning false when the queue is full or the pool is stopped.
+// 'done', if set, is called once the request has been processed.
+func (p *workerPool) Submit(ctx context.Context, msg *nats.Msg, done func()) bool {
+	select {
+	case <-p.stop:
+		return false
+	default:
+	}
+
+	p.inflight.Add(1)
+	select {
+	case p.jobs <- poolJob{ctx: ctx, msg: msg, done: done}:
+		return true
+	default:
+		p.inflight.Done()
+		return false
+	}
+}
+
+// Wait blocks until every submitted request has been processed.
+func (p *workerPool) Wait() {
+	p.inflight.Wait()
+}
+
+// Stop terminates workers once they finish their current request.
+// Requests still queued are passed to 'abandon', so they can be answered.
+func (p *workerPool) Stop(abandon func(*nats.Msg)) {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	p.workers.Wait()
+
+	for {
+		select {
+		case job := <-p.jobs:
+			abandon(job.msg)
+			if job.done != nil {
+				job.done()
+			}
+			p.inflight.Done()
+		default:
+			return
+		}
+	}
+}
+
+func (p *workerPool) Pending() int {
+	return len(p.jobs)
+}
+
+func (p *workerPool) Capacity() int {
+	return cap(p.jobs)
+}
@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestWorkerPool(t *testing.T) {
+	release := make(chan struct{})
+	var processed atomic.Int32
+
+	pool := newWorkerPool(1)
+	pool.Start(2, func(context.Context, *nats.Msg) {
+		<-release
+		processed.Add(1)
+	})
+
+	ctx := context.Background()
+
+	// keep both workers busy
+	for i := 0; i < 2; i++ {
+		if !pool.Submit(ctx, &nats.Msg{}, nil) {
+			t.Fatal("worker should accept request")
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for pool.Pending() != 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if !pool.Submit(ctx, &nats.Msg{}, nil) {
+		t.Fatal("queue should accept request")
+	}
+
+	if want, got := 1, pool.Pending(); want != got {
+		t.Errorf("pending: want %v, got %v", want, got)
+	}
+
+	if want, got := 1, pool.Capacity(); want != got {
+		t.Errorf("capacity: want %v, got %v", want, got)
+	}
+
+	if pool.Submit(ctx, &nats.Msg{}, nil) {
+		t.Fatal("full queue should reject request")
+	}
+
+	close(release)
+	pool.Wait()
+	pool.Stop(func(*nats.Msg) { t.Error("no request should be abandoned") })
+
+	if want, got := int32(3), processed.Load(); want != got {
+		t.Errorf("processed: want %v, got %v", want, got)
+	}
+}
+
+func TestWorkerPoolStop(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var processed atomic.Int32
+
+	pool := newWorkerPool(2)
+	pool.Start(1, func(context.Context, *nats.Msg) {
+		started <- struct{}{}
+		<-release
+		processed.Add(1)
+	})
+
+	ctx := context.Background()
+
+	var done atomic.Int32
+	for i := 0; i < 3; i++ {
+		if !pool.Submit(ctx, &nats.Msg{}, func() { done.Add(1) }) {
+			t.Fatal("pool should accept request")
+		}
+		if i == 0 {
+			<-started
+		}
+	}
+
+	stopped := make(chan struct{})
+	var abandoned atomic.Int32
+	go func() {
+		defer close(stopped)
+		pool.Stop(func(*nats.Msg) { abandoned.Add(1) })
+	}()
+
+	<-pool.stop
+	close(release)
+	<-stopped
+
+	// the worker may pick queued requests before noticing the pool stopped
+	if want, got := int32(3), processed.Load()+abandoned.Load(); want != got {
+		t.Errorf("processed or abandoned: want %v, got %v", want, got)
+	}
+	if want, got := int32(3), done.Load(); want != got {
+		t.Errorf("done: want %v, got %v", want, got)
+	}
+
+	// every request is accounted for
+	pool.Wait()
+
+	if pool.Submit(ctx, &nats.Msg{}, nil) {
+		t.Error("stopped pool should reject requests")
+	}
+}
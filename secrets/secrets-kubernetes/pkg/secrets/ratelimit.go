@@ -0,0 +1,155 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitSweepInterval is how often idle buckets are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit configures a token bucket: 'Rate' requests per second on average, in bursts of up to 'Burst' requests.
+// A zero 'Burst' allows bursts of 'Rate' requests ( at least one ). A zero 'Rate' disables the limit.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst,omitempty"`
+}
+
+func (l RateLimit) validate() error {
+	if l.Rate < 0 {
+		return fmt.Errorf("negative rate limit")
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("negative rate limit burst")
+	}
+	return nil
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return int(math.Max(1, math.Ceil(l.Rate)))
+}
+
+// validRateLimitSubject reports whether 'subject' is a host ( 'N' ), module ( 'M' ) or provider ( 'V' ) key.
+func validRateLimitSubject(subject string) bool {
+	if nkeys.IsValidPublicServerKey(subject) {
+		return true
+	}
+	return subject != "" && (subject[0] == 'M' || subject[0] == 'V') && nkeys.IsValidEncoding([]byte(subject))
+}
+
+// rateLimiter keeps one token bucket per key.
+type rateLimiter struct {
+	sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets:   make(map[string]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// AllowN takes 'n' tokens from the 'key' bucket, returning false when it doesn't have enough.
+// More than the burst size is never allowed.
+func (l *rateLimiter) AllowN(key string, limit RateLimit, n int) bool {
+	if limit.Rate == 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = rate.NewLimiter(rate.Limit(limit.Rate), limit.burst())
+		l.buckets[key] = bucket
+	} else if bucket.Limit() != rate.Limit(limit.Rate) || bucket.Burst() != limit.burst() {
+		bucket.SetLimitAt(now, rate.Limit(limit.Rate))
+		bucket.SetBurstAt(now, limit.burst())
+	}
+
+	return bucket.AllowN(now, n)
+}
+
+// sweep drops full buckets, they behave exactly like new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Len returns the number of tracked buckets.
+func (l *rateLimiter) Len() int {
+	l.Lock()
+	defer l.Unlock()
+
+	return len(l.buckets)
+}
+
+// checkRateLimits takes 'n' tokens, one per requested secret, from the host and entity buckets of the request.
+// Buckets are keyed by JWT subjects, so 'reqCtx' must have been validated first.
+// Limits come from server options only, requests can't change them.
+func (s *Server) checkRateLimits(reqCtx Context, n int) *ResponseError {
+	overrides := len(s.rateLimitOverrides) > 0
+
+	if s.hostRateLimit.Rate > 0 || overrides {
+		hostCap, _, err := reqCtx.HostCapabilities()
+		if err != nil {
+			return err
+		}
+		if err := s.allowN("host:", hostCap.Subject, s.hostRateLimit, n); err != nil {
+			return err
+		}
+	}
+
+	if s.entityRateLimit.Rate > 0 || overrides {
+		entityCap, _, err := reqCtx.EntityCapabilities()
+		if err != nil {
+			return err
+		}
+		if err := s.allowN("entity:", entityCap.Subject, s.entityRateLimit, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allowN takes 'n' tokens from the bucket of 'subject'. Requests for more secrets than the burst fail with ErrInvalidRequest
+// rather than ErrRateLimited: the bucket never holds that many tokens, so retrying can't help.
+func (s *Server) allowN(bucketPrefix string, subject string, limit RateLimit, n int) *ResponseError {
+	limit = s.rateLimit(subject, limit)
+	if limit.Rate > 0 && n > limit.burst() {
+		return ErrInvalidRequest
+	}
+	if !s.limiter.AllowN(bucketPrefix+subject, limit, n) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// rateLimit returns the limit for 'subject', see WithRateLimitOverrides.
+func (s *Server) rateLimit(subject string, limit RateLimit) RateLimit {
+	if override, ok := s.rateLimitOverrides[subject]; ok {
+		return override
+	}
+	return limit
+}
@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter()
+
+	limit := RateLimit{Rate: 1, Burst: 2}
+	for i := 0; i < 2; i++ {
+		if !limiter.AllowN("a", limit, 1) {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+
+	if limiter.AllowN("a", limit, 1) {
+		t.Error("bucket should be empty")
+	}
+
+	if !limiter.AllowN("b", limit, 1) {
+		t.Error("buckets should be independent")
+	}
+
+	for i := 0; i < 10; i++ {
+		if !limiter.AllowN("c", RateLimit{}, 1) {
+			t.Fatal("zero rate shouldn't limit")
+		}
+	}
+
+	if want, got := 2, limiter.Len(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// full buckets are dropped
+	limiter.sweep(time.Now().Add(time.Hour))
+	if want, got := 0, limiter.Len(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// batches take one token per item
+	if !limiter.AllowN("d", limit, 2) {
+		t.Error("batch within the burst should be allowed")
+	}
+	if limiter.AllowN("d", limit, 1) {
+		t.Error("batch should have emptied the bucket")
+	}
+	if limiter.AllowN("e", limit, 3) {
+		t.Error("batch larger than the burst shouldn't be allowed")
+	}
+}
+
+func TestServerRateLimitBatch(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	handler := &testHandler{
+		getFunc: func(context.Context, *Request) (*SecretValue, error) {
+			return &SecretValue{StringSecret: "value"}, nil
+		},
+	}
+
+	server, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithEntityRateLimit(RateLimit{Rate: 0.001, Burst: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	client, err := NewClient("kube", nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more items than the burst can never be admitted, and take no tokens
+	oversize := &BatchRequest{
+		Items:   []BatchItem{{Key: "a"}, {Key: "b"}, {Key: "c"}, {Key: "d"}},
+		Context: contextForTest(),
+	}
+	if _, err := client.GetBatch(context.Background(), oversize); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("want %v, got %v", ErrInvalidRequest, err)
+	}
+
+	batch := &BatchRequest{
+		Items:   []BatchItem{{Key: "a", Field: "password"}, {Key: "b", Field: "password"}},
+		Context: contextForTest(),
+	}
+
+	if _, err := client.GetBatch(context.Background(), batch); err != nil {
+		t.Fatalf("first batch should succeed, got %v", err)
+	}
+
+	// only one token is left for two items
+	if _, err := client.GetBatch(context.Background(), batch); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("want %v, got %v", ErrRateLimited, err)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	handler := &testHandler{
+		getFunc: func(context.Context, *Request) (*SecretValue, error) {
+			return &SecretValue{StringSecret: "value"}, nil
+		},
+	}
+
+	if _, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithHostRateLimit(RateLimit{Rate: -1})); err == nil {
+		t.Error("rate shouldn't be negative")
+	}
+
+	server, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithHostRateLimit(RateLimit{Rate: 0.001, Burst: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	kp := keyPairForTest(t)
+
+	get := func(reqCtx Context) *nats.Msg {
+		reply, err := nc.RequestMsg(getRequestForTest(t, server, kp, &Request{Key: "secret", Context: reqCtx}), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	if reply := get(contextForTest()); reply.Header.Get(WasmCloudResponseXkey) == "" {
+		t.Fatalf("first request should succeed, got %s", reply.Data)
+	}
+
+	if got := responseErrorForTest(t, get(contextForTest())); !errors.Is(got, ErrRateLimited) {
+		t.Errorf("want %v, got %v", ErrRateLimited, got)
+	}
+
+	// requests can't lift the limit
+	unlimited := contextForTest()
+	unlimited.Application.Policy = `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{"rateLimits":{"host":{"rate":0}}}}`
+
+	if got := responseErrorForTest(t, get(unlimited)); !errors.Is(got, ErrRateLimited) {
+		t.Errorf("request override should be ignored, want %v, got %v", ErrRateLimited, got)
+	}
+}
+
+func TestServerRateLimitOverrides(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	handler := &testHandler{
+		getFunc: func(context.Context, *Request) (*SecretValue, error) {
+			return &SecretValue{StringSecret: "value"}, nil
+		},
+	}
+
+	if _, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithRateLimitOverrides(map[string]RateLimit{"app": {Rate: 1}})); err == nil {
+		t.Error("overrides should be keyed by JWT subjects")
+	}
+
+	server, err := NewServer("kube", nc, handler, WithEphemeralKey(),
+		WithEntityRateLimit(RateLimit{Rate: 0.001, Burst: 1}),
+		WithRateLimitOverrides(map[string]RateLimit{testModuleKey: {Rate: 0}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	kp := keyPairForTest(t)
+
+	for i := 0; i < 3; i++ {
+		reply, err := nc.RequestMsg(getRequestForTest(t, server, kp, &Request{Key: "secret", Context: contextForTest()}), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.Header.Get(WasmCloudResponseXkey) == "" {
+			t.Fatalf("request %d should be allowed by the override, got %s", i, reply.Data)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// replaySweepInterval is how often expired entries are dropped from the in-memory store.
+const replaySweepInterval = time.Minute
+
+// ReplayStore remembers requests the server has already answered.
+type ReplayStore interface {
+	// Seen records 'key' for 'ttl', returning true if it was already recorded.
+	Seen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryReplayStore is a ReplayStore local to the process.
+// Replicas using it don't share what they've seen, use KeyValueReplayStore for that.
+type MemoryReplayStore struct {
+	sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+var _ ReplayStore = &MemoryReplayStore{}
+
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{
+		entries:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *MemoryReplayStore) Seen(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > replaySweepInterval {
+		for k, expiry := range m.entries {
+			if now.After(expiry) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	if expiry, ok := m.entries[key]; ok && now.Before(expiry) {
+		return true, nil
+	}
+
+	m.entries[key] = now.Add(ttl)
+	return false, nil
+}
+
+// Len returns the number of tracked requests, expired ones included until the next sweep.
+func (m *MemoryReplayStore) Len() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return len(m.entries)
+}
+
+// KeyValueReplayStore is a ReplayStore backed by a NATS KV bucket, shared by all replicas.
+// Entries expire with the bucket TTL, which should be at least the replay window.
+type KeyValueReplayStore struct {
+	kv nats.KeyValue
+}
+
+var _ ReplayStore = &KeyValueReplayStore{}
+
+func NewKeyValueReplayStore(kv nats.KeyValue) *KeyValueReplayStore {
+	return &KeyValueReplayStore{kv: kv}
+}
+
+func (k *KeyValueReplayStore) Seen(_ context.Context, key string, _ time.Duration) (bool, error) {
+	_, err := k.kv.Create(key, nil)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return true, nil
+	}
+	return false, err
+}
+
+// replayKey identifies a sealed request. Sealing uses a random nonce, so hosts never send the same payload twice.
+func replayKey(hostPubKey string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(hostPubKey))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkReplay rejects requests that were already seen, or whose timestamp is outside the replay window.
+func (s *Server) checkReplay(ctx context.Context, hostPubKey string, data []byte, timestamp int64) *ResponseError {
+	if s.replayStore == nil {
+		return nil
+	}
+
+	if timestamp == 0 {
+		if s.requireTimestamp {
+			return ErrPolicy.With("missing request timestamp")
+		}
+	} else {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > s.replayWindow || age < -s.replayWindow {
+			return ErrPolicy.With("request timestamp outside of the replay window")
+		}
+	}
+
+	seen, err := s.replayStore.Seen(ctx, replayKey(hostPubKey, data), s.replayWindow)
+	if err != nil {
+		return ErrOther.With(fmt.Sprintf("replay check: %s", err))
+	}
+	if seen {
+		return ErrReplay
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+func TestMemoryReplayStore(t *testing.T) {
+	store := NewMemoryReplayStore()
+	ctx := context.Background()
+
+	if seen, err := store.Seen(ctx, "a", time.Minute); err != nil || seen {
+		t.Fatalf("first sighting: seen %v, err %v", seen, err)
+	}
+
+	if seen, err := store.Seen(ctx, "a", time.Minute); err != nil || !seen {
+		t.Errorf("second sighting: seen %v, err %v", seen, err)
+	}
+
+	if seen, _ := store.Seen(ctx, "b", -time.Second); seen {
+		t.Error("b wasn't seen yet")
+	}
+
+	if seen, _ := store.Seen(ctx, "b", time.Minute); seen {
+		t.Error("expired entries shouldn't count")
+	}
+
+	if want, got := 2, store.Len(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestKeyValueReplayStore(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := natsserver.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "replay", TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewKeyValueReplayStore(kv)
+	ctx := context.Background()
+
+	if seen, err := store.Seen(ctx, replayKey("host", []byte("data")), time.Minute); err != nil || seen {
+		t.Fatalf("first sighting: seen %v, err %v", seen, err)
+	}
+
+	if seen, err := store.Seen(ctx, replayKey("host", []byte("data")), time.Minute); err != nil || !seen {
+		t.Errorf("second sighting: seen %v, err %v", seen, err)
+	}
+}
+
+func TestServerReplay(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	handler := &testHandler{
+		getFunc: func(context.Context, *Request) (*SecretValue, error) {
+			return &SecretValue{StringSecret: "value"}, nil
+		},
+	}
+
+	if _, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithRequireRequestTimestamp()); err == nil {
+		t.Error("timestamps shouldn't be required without replay protection")
+	}
+
+	if _, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithReplayProtection(NewMemoryReplayStore(), 0)); err == nil {
+		t.Error("replay window should be positive")
+	}
+
+	server, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithReplayProtection(NewMemoryReplayStore(), time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	kp := keyPairForTest(t)
+
+	tests := map[string]struct {
+		timestamp int64
+		replay    bool
+		wantError *ResponseError
+	}{
+		"noTimestamp": {},
+		"freshTimestamp": {
+			timestamp: time.Now().Unix(),
+		},
+		"staleTimestamp": {
+			timestamp: time.Now().Add(-time.Hour).Unix(),
+			wantError: ErrPolicy,
+		},
+		"replayed": {
+			replay:    true,
+			wantError: ErrReplay,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg := getRequestForTest(t, server, kp, &Request{Key: "secret", Context: contextForTest(), Timestamp: test.timestamp})
+
+			if test.replay {
+				if _, err := nc.RequestMsg(msg, time.Second); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reply, err := nc.RequestMsg(msg, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.wantError == nil {
+				if reply.Header.Get(WasmCloudResponseXkey) == "" {
+					t.Errorf("request should succeed, got %s", reply.Data)
+				}
+				return
+			}
+
+			if got := responseErrorForTest(t, reply); !errors.Is(got, test.wantError) {
+				t.Errorf("want %v, got %v", test.wantError, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	_ Handler = &Router{}
+	_ Lister  = &Router{}
+)
+
+// Router is a Handler dispatching requests to other Handlers, so one server can front several backends.
+// Requests are routed by the 'route' property of the application policy first, then by the longest matching key prefix.
+// The policy 'backend' property isn't used: wasmCloud hosts always set it to the service name the request is sent to.
+// A policy naming an unregistered route is rejected with ErrPolicy.
+type Router struct {
+	sync.RWMutex
+	routes   map[string]Handler
+	prefixes []routerPrefix
+	fallback Handler
+}
+
+type routerPrefix struct {
+	prefix  string
+	handler Handler
+}
+
+type routerPolicy struct {
+	Route string `json:"route"`
+}
+
+func NewRouter() *Router {
+	return &Router{
+		routes: make(map[string]Handler),
+	}
+}
+
+// HandleRoute routes requests whose policy 'route' property is 'name' to 'h'.
+func (r *Router) HandleRoute(name string, h Handler) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.routes[name] = h
+}
+
+// HandlePrefix routes requests whose key starts with 'prefix' to 'h'. The prefix is stripped from the key.
+func (r *Router) HandlePrefix(prefix string, h Handler) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.prefixes = append(r.prefixes, routerPrefix{prefix: prefix, handler: h})
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+}
+
+// HandleDefault routes requests matching no route or prefix to 'h'.
+func (r *Router) HandleDefault(h Handler) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.fallback = h
+}
+
+func (r *Router) Get(ctx context.Context, req *Request) (*SecretValue, error) {
+	h, routed, err := r.route(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Get(ctx, routed)
+}
+
+// List routes like Get. Without a key or 'route' policy property, listings of every prefix handler and the
+// default handler are merged, with their prefix added back. Handlers that don't implement Lister are skipped.
+func (r *Router) List(ctx context.Context, req *Request) ([]SecretListing, error) {
+	if req.Key != "" || policyRoute(req.Context) != "" {
+		h, routed, err := r.route(req)
+		if err != nil {
+			return nil, err
+		}
+
+		lister, ok := h.(Lister)
+		if !ok {
+			return nil, ErrPolicy.With("backend can't list secrets")
+		}
+
+		listings, err := lister.List(ctx, routed)
+		if err != nil {
+			return nil, err
+		}
+
+		return prefixListings(strings.TrimSuffix(req.Key, routed.Key), listings), nil
+	}
+
+	r.RLock()
+	prefixes := append([]routerPrefix{}, r.prefixes...)
+	if r.fallback != nil {
+		prefixes = append(prefixes, routerPrefix{handler: r.fallback})
+	}
+	r.RUnlock()
+
+	var all []SecretListing
+	for _, p := range prefixes {
+		lister, ok := p.handler.(Lister)
+		if !ok {
+			continue
+		}
+
+		listings, err := lister.List(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, prefixListings(p.prefix, listings)...)
+	}
+
+	return all, nil
+}
+
+func prefixListings(prefix string, listings []SecretListing) []SecretListing {
+	if prefix == "" {
+		return listings
+	}
+
+	prefixed := make([]SecretListing, len(listings))
+	for i, l := range listings {
+		prefixed[i] = SecretListing{Key: prefix + l.Key, Fields: l.Fields}
+	}
+
+	return prefixed
+}
+
+// route picks the Handler for 'req', returning the request it should receive.
+// A policy naming a route that isn't registered is rejected rather than routed elsewhere.
+func (r *Router) route(req *Request) (Handler, *Request, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if name := policyRoute(req.Context); name != "" {
+		h, ok := r.routes[name]
+		if !ok {
+			return nil, nil, ErrPolicy.With("unknown route " + name)
+		}
+		return h, req, nil
+	}
+
+	for _, p := range r.prefixes {
+		if strings.HasPrefix(req.Key, p.prefix) {
+			routed := *req
+			routed.Key = strings.TrimPrefix(req.Key, p.prefix)
+			return p.handler, &routed, nil
+		}
+	}
+
+	if r.fallback == nil {
+		return nil, nil, ErrPolicy.With("no backend for request")
+	}
+
+	return r.fallback, req, nil
+}
+
+// policyRoute returns the 'route' property of the application policy, if any.
+func policyRoute(ctx Context) string {
+	if ctx.Application == nil || ctx.Application.Policy == "" {
+		return ""
+	}
+
+	properties, err := ctx.Application.PolicyProperties()
+	if err != nil || len(properties) == 0 {
+		return ""
+	}
+
+	policy := &routerPolicy{}
+	if err := json.Unmarshal(properties, policy); err != nil {
+		return ""
+	}
+
+	return policy.Route
+}
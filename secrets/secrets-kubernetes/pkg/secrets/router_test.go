@@ -0,0 +1,167 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func namedHandlerForTest(name string) Handler {
+	return &testHandler{
+		getFunc: func(_ context.Context, r *Request) (*SecretValue, error) {
+			return &SecretValue{StringSecret: name + ":" + r.Key}, nil
+		},
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	router.HandleRoute("kube", namedHandlerForTest("kube"))
+	router.HandleRoute("file", namedHandlerForTest("file"))
+	router.HandlePrefix("file/", namedHandlerForTest("file"))
+	router.HandlePrefix("file/nested/", namedHandlerForTest("nested"))
+
+	// wasmCloud hosts set 'backend' to the service name the request is sent to
+	hostPolicy := &ApplicationContext{
+		Name:   "app",
+		Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{"backend":"kube"}}`,
+	}
+	policy := func(route string) *ApplicationContext {
+		return &ApplicationContext{
+			Name:   "app",
+			Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{"backend":"kube","route":"` + route + `"}}`,
+		}
+	}
+
+	tests := map[string]struct {
+		req       Request
+		want      string
+		wantError bool
+	}{
+		"route": {
+			req:  Request{Key: "secret", Context: Context{Application: policy("kube")}},
+			want: "kube:secret",
+		},
+		"routeBeforePrefix": {
+			req:  Request{Key: "file/secret", Context: Context{Application: policy("kube")}},
+			want: "kube:file/secret",
+		},
+		"prefix": {
+			req:  Request{Key: "file/secret"},
+			want: "file:secret",
+		},
+		"hostPolicyPrefix": {
+			req:  Request{Key: "file/secret", Context: Context{Application: hostPolicy}},
+			want: "file:secret",
+		},
+		"hostPolicyNoRoute": {
+			req:       Request{Key: "secret", Context: Context{Application: hostPolicy}},
+			wantError: true,
+		},
+		"longestPrefix": {
+			req:  Request{Key: "file/nested/secret"},
+			want: "nested:secret",
+		},
+		"unknownRoute": {
+			req:       Request{Key: "file/secret", Context: Context{Application: policy("vault")}},
+			wantError: true,
+		},
+		"noRoute": {
+			req:       Request{Key: "secret"},
+			wantError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			value, err := router.Get(context.Background(), &test.req)
+			if test.wantError {
+				if !errors.Is(err, ErrPolicy) {
+					t.Fatalf("want %v, got %v", ErrPolicy, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want, got := test.want, value.StringSecret; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+
+	router.HandleDefault(namedHandlerForTest("default"))
+	value, err := router.Get(context.Background(), &Request{Key: "secret", Context: Context{Application: hostPolicy}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "default:secret", value.StringSecret; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestRouterList(t *testing.T) {
+	router := NewRouter()
+	router.HandleRoute("kube", listerForTest())
+	router.HandlePrefix("file/", listerForTest())
+	router.HandlePrefix("plain/", namedHandlerForTest("plain"))
+	router.HandleDefault(listerForTest())
+
+	kubePolicy := &ApplicationContext{
+		Name:   "app",
+		Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{"backend":"kube","route":"kube"}}`,
+	}
+	hostPolicy := &ApplicationContext{
+		Name:   "app",
+		Policy: `{"type":"properties.secret.wasmcloud.dev/v1alpha1","properties":{"backend":"kube"}}`,
+	}
+
+	tests := map[string]struct {
+		req       Request
+		want      []SecretListing
+		wantError bool
+	}{
+		"route": {
+			req:  Request{Context: Context{Application: kubePolicy}},
+			want: []SecretListing{{Key: "a", Fields: []string{"password", "username"}}, {Key: "b", Fields: []string{"token"}}},
+		},
+		"prefixedKey": {
+			req:  Request{Key: "file/c"},
+			want: []SecretListing{{Key: "file/c", Fields: []string{"only"}}},
+		},
+		"notLister": {
+			req:       Request{Key: "plain/c"},
+			wantError: true,
+		},
+		"merged": {
+			req: Request{Context: Context{Application: hostPolicy}},
+			want: []SecretListing{
+				{Key: "file/a", Fields: []string{"password", "username"}},
+				{Key: "file/b", Fields: []string{"token"}},
+				{Key: "a", Fields: []string{"password", "username"}},
+				{Key: "b", Fields: []string{"token"}},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			listings, err := router.List(context.Background(), &test.req)
+			if test.wantError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(test.want, listings) {
+				t.Errorf("want %v, got %v", test.want, listings)
+			}
+		})
+	}
+}
@@ -3,26 +3,72 @@ package secrets
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nkeys"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type (
 	ServerErrorCallback  func(msg *nats.Msg, err error)
 	ServerContextCreator func() context.Context
+	// ServerRequestCallback is called once per processed message with its outcome.
+	// 'operation' is one of the operations the server answers, or "unknown". 'err' is nil for successful requests.
+	ServerRequestCallback func(msg *nats.Msg, operation string, err *ResponseError, elapsed time.Duration)
+	// ServerAuditCallback is called once per access decision: for every 'get' and 'list' request, and every 'batch_get' item.
+	// 'req' is nil when the request couldn't be read, e.g. undecryptable or rejected before processing.
+	// 'value' is set for granted 'get' requests and batch items, 'err' is nil when access was granted.
+	// 'req.Context.Verified()' reports whether its JWTs passed validation, denied requests may carry forged or expired ones.
+	ServerAuditCallback func(msg *nats.Msg, operation string, req *Request, value *SecretValue, err *ResponseError)
 )
 
 type Server struct {
-	queue         *nats.Subscription
+	// set by Run, read by Ready from other goroutines
+	queue         atomic.Pointer[nats.Subscription]
 	natsConn      *nats.Conn
 	handler       Handler
 	onError       ServerErrorCallback
+	onRequest     ServerRequestCallback
+	onAudit       ServerAuditCallback
+	keysLock      sync.RWMutex
 	key           nkeys.KeyPair
 	pubKey        string
+	retiredKeys   []nkeys.KeyPair
 	subjectMapper SubjectMapper
 	ctxCreator    ServerContextCreator
+	validation    ValidationOptions
+	tracer        trace.Tracer
+	propagator    propagation.TextMapPropagator
+	concurrency   int
+	queueSize     int
+	timeout       time.Duration
+	pool          *workerPool
+
+	hostRateLimit   RateLimit
+	entityRateLimit RateLimit
+	// limits by host or entity JWT subject, replacing hostRateLimit and entityRateLimit
+	rateLimitOverrides map[string]RateLimit
+	limiter            *rateLimiter
+
+	replayStore      ReplayStore
+	replayWindow     time.Duration
+	requireTimestamp bool
+
+	listEnabled    bool
+	listEntityRule EntityRule
+	listHostRule   HostRule
+
+	maxBatchSize int
 }
 
 type ServerOption func(*Server) error
@@ -56,6 +102,73 @@ func WithErrorCallback(cb ServerErrorCallback) ServerOption {
 	}
 }
 
+func WithRequestCallback(cb ServerRequestCallback) ServerOption {
+	return func(s *Server) error {
+		s.onRequest = cb
+		return nil
+	}
+}
+
+// WithAuditCallback records access decisions, including requests denied before reaching the handler.
+func WithAuditCallback(cb ServerAuditCallback) ServerOption {
+	return func(s *Server) error {
+		s.onAudit = cb
+		return nil
+	}
+}
+
+// WithTracerProvider sets the provider used to trace requests. Defaults to the global provider.
+func WithTracerProvider(tp trace.TracerProvider) ServerOption {
+	return func(s *Server) error {
+		s.tracer = tp.Tracer(tracerName)
+		return nil
+	}
+}
+
+// WithPropagator sets how trace context is extracted from message headers. Defaults to the global propagator, see otel.SetTextMapPropagator.
+func WithPropagator(p propagation.TextMapPropagator) ServerOption {
+	return func(s *Server) error {
+		s.propagator = p
+		return nil
+	}
+}
+
+// WithConcurrency processes up to 'n' requests in parallel, instead of one at a time.
+// Requests wait in a bounded queue (see WithQueueSize) and are rejected with ErrServerBusy when it is full.
+func WithConcurrency(n int) ServerOption {
+	return func(s *Server) error {
+		if n < 1 {
+			return fmt.Errorf("concurrency must be at least 1")
+		}
+		s.concurrency = n
+		return nil
+	}
+}
+
+// WithQueueSize bounds how many requests can wait for a worker. Only used alongside WithConcurrency.
+func WithQueueSize(n int) ServerOption {
+	return func(s *Server) error {
+		if n < 0 {
+			return fmt.Errorf("negative queue size")
+		}
+		s.queueSize = n
+		return nil
+	}
+}
+
+// WithRequestTimeout bounds how long a request can take, from the moment it is received, queueing included.
+// The handler context is cancelled once it expires and the request is answered with ErrTimeout.
+// The worker processing the request is only released once the handler returns.
+func WithRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) error {
+		if d < 0 {
+			return fmt.Errorf("negative request timeout")
+		}
+		s.timeout = d
+		return nil
+	}
+}
+
 func WithRequestContext(cb ServerContextCreator) ServerOption {
 	return func(s *Server) error {
 		s.ctxCreator = cb
@@ -63,12 +176,154 @@ func WithRequestContext(cb ServerContextCreator) ServerOption {
 	}
 }
 
+// WithTrustedEntityIssuers restricts which account/operator keys can issue entity (component & provider) JWTs.
+func WithTrustedEntityIssuers(issuers ...string) ServerOption {
+	return func(s *Server) error {
+		for _, iss := range issuers {
+			if !nkeys.IsValidPublicAccountKey(iss) && !nkeys.IsValidPublicOperatorKey(iss) {
+				return fmt.Errorf("invalid entity issuer '%s'", iss)
+			}
+		}
+		s.validation.TrustedEntityIssuers = issuers
+		return nil
+	}
+}
+
+// WithTrustedHostIssuers restricts which account/operator/cluster keys can issue host JWTs.
+// wasmCloud hosts sign their own JWT with the lattice cluster key.
+func WithTrustedHostIssuers(issuers ...string) ServerOption {
+	return func(s *Server) error {
+		for _, iss := range issuers {
+			if !nkeys.IsValidPublicAccountKey(iss) && !nkeys.IsValidPublicOperatorKey(iss) && !nkeys.IsValidPublicClusterKey(iss) {
+				return fmt.Errorf("invalid host issuer '%s'", iss)
+			}
+		}
+		s.validation.TrustedHostIssuers = issuers
+		return nil
+	}
+}
+
+// WithClockSkew tolerates clock differences between hosts and the server when checking JWT time claims.
+func WithClockSkew(skew time.Duration) ServerOption {
+	return func(s *Server) error {
+		if skew < 0 {
+			return fmt.Errorf("negative clock skew")
+		}
+		s.validation.ClockSkew = skew
+		return nil
+	}
+}
+
+// WithRequireExpiration rejects entity and host JWTs without an expiration claim.
+func WithRequireExpiration() ServerOption {
+	return func(s *Server) error {
+		s.validation.RequireExpiration = true
+		return nil
+	}
+}
+
+// WithHostRateLimit limits how many secrets each host ( by host JWT subject ) can request, each batch item counting once.
+// Requests over the limit fail with ErrRateLimited, batches larger than the burst with ErrInvalidRequest.
+// See WithRateLimitOverrides for per-host limits.
+func WithHostRateLimit(limit RateLimit) ServerOption {
+	return func(s *Server) error {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+		s.hostRateLimit = limit
+		return nil
+	}
+}
+
+// WithEntityRateLimit limits how many secrets each component or provider ( by entity JWT subject ) can request,
+// each batch item counting once. Requests over the limit fail with ErrRateLimited, batches larger than the burst with
+// ErrInvalidRequest. See WithRateLimitOverrides for per-entity limits.
+func WithEntityRateLimit(limit RateLimit) ServerOption {
+	return func(s *Server) error {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+		s.entityRateLimit = limit
+		return nil
+	}
+}
+
+// WithRateLimitOverrides replaces the host or entity rate limit for specific JWT subjects: host ( 'N' ), module ( 'M' ) or provider ( 'V' ) keys.
+// A zero 'Rate' lifts the limit for that subject.
+func WithRateLimitOverrides(overrides map[string]RateLimit) ServerOption {
+	return func(s *Server) error {
+		for subject, limit := range overrides {
+			if !validRateLimitSubject(subject) {
+				return fmt.Errorf("invalid rate limit subject '%s'", subject)
+			}
+			if err := limit.validate(); err != nil {
+				return fmt.Errorf("%s: %w", subject, err)
+			}
+		}
+		s.rateLimitOverrides = overrides
+		return nil
+	}
+}
+
+// WithReplayProtection rejects requests seen within 'window' with ErrReplay, as well as requests with a timestamp outside of it.
+func WithReplayProtection(store ReplayStore, window time.Duration) ServerOption {
+	return func(s *Server) error {
+		if store == nil {
+			return fmt.Errorf("missing replay store")
+		}
+		if window <= 0 {
+			return fmt.Errorf("replay window must be positive")
+		}
+		s.replayStore = store
+		s.replayWindow = window
+		return nil
+	}
+}
+
+// WithRequireRequestTimestamp rejects requests without a timestamp, so captured requests can't be replayed once the window has passed.
+// Requires WithReplayProtection.
+func WithRequireRequestTimestamp() ServerOption {
+	return func(s *Server) error {
+		s.requireTimestamp = true
+		return nil
+	}
+}
+
+// WithList enables the 'list' operation. The handler must implement Lister.
+// Listing is authorized separately from 'get': callers must match both rules, empty rules allow any verified caller.
+func WithList(entity EntityRule, host HostRule) ServerOption {
+	return func(s *Server) error {
+		s.listEnabled = true
+		s.listEntityRule = entity
+		s.listHostRule = host
+		return nil
+	}
+}
+
+// WithMaxBatchSize bounds how many secrets a 'batch_get' request can fetch. Larger batches fail with ErrInvalidRequest.
+func WithMaxBatchSize(n int) ServerOption {
+	return func(s *Server) error {
+		if n < 1 {
+			return fmt.Errorf("max batch size must be at least 1")
+		}
+		s.maxBatchSize = n
+		return nil
+	}
+}
+
 func NewServer(name string, nc *nats.Conn, handler Handler, opts ...ServerOption) (*Server, error) {
 	server := &Server{
-		natsConn:   nc,
-		handler:    handler,
-		onError:    func(*nats.Msg, error) {},
-		ctxCreator: func() context.Context { return context.Background() },
+		natsConn:     nc,
+		handler:      handler,
+		onError:      func(*nats.Msg, error) {},
+		onRequest:    func(*nats.Msg, string, *ResponseError, time.Duration) {},
+		onAudit:      func(*nats.Msg, string, *Request, *SecretValue, *ResponseError) {},
+		ctxCreator:   func() context.Context { return context.Background() },
+		tracer:       otel.GetTracerProvider().Tracer(tracerName),
+		propagator:   otel.GetTextMapPropagator(),
+		queueSize:    DefaultQueueSize,
+		maxBatchSize: DefaultMaxBatchSize,
+		limiter:      newRateLimiter(),
 		subjectMapper: SubjectMapper{
 			Version:     DefaultSecretsProtocolVersion,
 			Prefix:      DefaultSecretsBusPrefix,
@@ -102,99 +357,197 @@ func NewServer(name string, nc *nats.Conn, handler Handler, opts ...ServerOption
 		return nil, fmt.Errorf("%w: context creator", ErrInvalidServerConfig)
 	}
 
-	var err error
-	server.pubKey, err = server.key.PublicKey()
-	if err != nil {
+	if server.requireTimestamp && server.replayStore == nil {
+		return nil, fmt.Errorf("%w: request timestamps require replay protection", ErrInvalidServerConfig)
+	}
+
+	if _, ok := server.handler.(Lister); server.listEnabled && !ok {
+		return nil, fmt.Errorf("%w: listing requires a Lister handler", ErrInvalidServerConfig)
+	}
+
+	if server.concurrency > 0 {
+		server.pool = newWorkerPool(server.queueSize)
+	}
+
+	if err := server.SetKeys(server.key, server.retiredKeys...); err != nil {
 		return nil, fmt.Errorf("%w: %s", ErrInvalidServerConfig, err)
 	}
 
 	return server, nil
 }
 
-func (s *Server) Process(ctx context.Context, msg *nats.Msg) {
-	nakCallback := func(respErr *ResponseError) {
-		s.onError(msg, respErr)
+// knownOperations are the operations answered by Process.
+var knownOperations = []string{"get", "list", "batch_get", "server_xkey"}
 
-		resp := Response{Error: respErr}
+// operation returns the operation requested by 'msg', or "unknown" for subjects outside knownOperations,
+// so hosts can't create arbitrary metric labels or span names.
+func (s *Server) operation(msg *nats.Msg) string {
+	operation := s.subjectMapper.ParseOperation(msg.Subject)
+	if !slices.Contains(knownOperations, operation) {
+		return "unknown"
+	}
+	return operation
+}
 
-		data, err := json.Marshal(&resp)
-		if err != nil {
-			s.onError(msg, err)
-			return
+func (s *Server) Process(ctx context.Context, msg *nats.Msg) {
+	start := time.Now()
+	operation := s.operation(msg)
+
+	var result *ResponseError
+	defer func() {
+		s.onRequest(msg, operation, result, time.Since(start))
+	}()
+
+	ctx = s.propagator.Extract(ctx, natsHeaderCarrier(msg.Header))
+	ctx, span := s.tracer.Start(ctx, "secrets "+operation,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", msg.Subject),
+			attribute.String("secrets.operation", operation),
+		))
+	defer func() {
+		if result != nil {
+			span.SetStatus(codes.Error, result.Kind)
+			span.SetAttributes(attribute.String("secrets.error", result.Kind))
 		}
+		span.End()
+	}()
 
-		if err := msg.Respond(data); err != nil {
-			s.onError(msg, err)
-		}
+	nakCallback := func(respErr *ResponseError) {
+		result = respErr
+		s.respondError(msg, respErr)
 	}
 
-	operation := s.subjectMapper.ParseOperation(msg.Subject)
 	switch operation {
 	case "get":
-		hostPubKey := msg.Header.Get(WasmCloudHostXkey)
-		if hostPubKey == "" {
-			nakCallback(ErrInvalidHeaders)
+		req := &Request{}
+		hostPubKey, respErr := s.openRequest(ctx, msg, req)
+		if respErr != nil {
+			s.onAudit(msg, operation, nil, nil, respErr)
+			nakCallback(respErr)
+			return
+		}
+
+		var secretValue *SecretValue
+		defer func() {
+			s.onAudit(msg, operation, req, secretValue, result)
+		}()
+
+		if respErr := s.admitRequest(ctx, hostPubKey, msg, req, 1); respErr != nil {
+			nakCallback(respErr)
 			return
 		}
 
-		rawReq, err := s.key.Open(msg.Data, hostPubKey)
+		span.SetAttributes(
+			attribute.String("secrets.key", req.Key),
+			attribute.String("secrets.field", req.Field),
+		)
+
+		handlerCtx, handlerSpan := s.tracer.Start(ctx, "handler get")
+		secretValue, wait, err := s.handle(handlerCtx, req)
+		defer wait()
+		handlerSpan.End()
 		if err != nil {
-			nakCallback(ErrDecryption)
+			nakCallback(handlerError(ctx, err))
 			return
 		}
 
+		if respErr := s.respondSealed(ctx, msg, hostPubKey, &Response{Secret: secretValue}); respErr != nil {
+			nakCallback(respErr)
+		}
+	case "list":
 		req := &Request{}
-		if err := json.Unmarshal(rawReq, &req); err != nil {
-			nakCallback(ErrInvalidPayload)
+		hostPubKey, respErr := s.openRequest(ctx, msg, req)
+		if respErr != nil {
+			s.onAudit(msg, operation, nil, nil, respErr)
+			nakCallback(respErr)
 			return
 		}
 
-		if err := req.Context.IsValid(); err != nil {
-			nakCallback(err)
+		defer func() {
+			s.onAudit(msg, operation, req, nil, result)
+		}()
+
+		if respErr := s.admitRequest(ctx, hostPubKey, msg, req, 1); respErr != nil {
+			nakCallback(respErr)
 			return
 		}
 
-		secretValue, err := s.handler.Get(ctx, req)
-		if err != nil {
-			if respErr, ok := err.(*ResponseError); ok {
-				nakCallback(respErr)
-			} else {
-				nakCallback(ErrUpstream.With(err.Error()))
-			}
+		if respErr := s.authorizeList(req.Context); respErr != nil {
+			nakCallback(respErr)
 			return
 		}
 
-		responseKey, err := nkeys.CreateCurveKeys()
+		span.SetAttributes(attribute.String("secrets.key", req.Key))
+
+		handlerCtx, handlerSpan := s.tracer.Start(ctx, "handler list")
+		listings, wait, err := s.list(handlerCtx, req)
+		defer wait()
+		handlerSpan.End()
 		if err != nil {
-			nakCallback(ErrEncryption)
+			nakCallback(handlerError(ctx, err))
 			return
 		}
-		ephemeralPubKey, err := responseKey.PublicKey()
-		if err != nil {
-			nakCallback(ErrEncryption)
+
+		if respErr := s.respondSealed(ctx, msg, hostPubKey, &ListResponse{Secrets: listings}); respErr != nil {
+			nakCallback(respErr)
+		}
+	case "batch_get":
+		req := &BatchRequest{}
+		hostPubKey, respErr := s.openRequest(ctx, msg, req)
+		if respErr != nil {
+			s.onAudit(msg, operation, nil, nil, respErr)
+			nakCallback(respErr)
 			return
 		}
 
-		data, err := json.Marshal(&Response{Secret: secretValue})
-		if err != nil {
-			nakCallback(ErrInvalidPayload)
+		var (
+			results []Response
+			err     error
+		)
+		defer func() {
+			// oversize batches are audited once, rather than once per item
+			if len(req.Items) > s.maxBatchSize {
+				s.onAudit(msg, operation, nil, nil, result)
+				return
+			}
+			for i := range req.Items {
+				if result != nil {
+					s.onAudit(msg, operation, req.Request(i), nil, result)
+				} else {
+					s.onAudit(msg, operation, req.Request(i), results[i].Secret, results[i].Error)
+				}
+			}
+		}()
+
+		if len(req.Items) > s.maxBatchSize {
+			nakCallback(ErrInvalidRequest)
 			return
 		}
 
-		respMsg := nats.NewMsg("")
-		respMsg.Header.Add(WasmCloudResponseXkey, ephemeralPubKey)
+		if respErr := s.admitRequest(ctx, hostPubKey, msg, req, len(req.Items)); respErr != nil {
+			nakCallback(respErr)
+			return
+		}
 
-		respMsg.Data, err = responseKey.Seal(data, hostPubKey)
+		span.SetAttributes(attribute.Int("secrets.batch_size", len(req.Items)))
+
+		handlerCtx, handlerSpan := s.tracer.Start(ctx, "handler batch_get")
+		var wait func()
+		results, wait, err = s.getBatch(handlerCtx, req)
+		defer wait()
+		handlerSpan.End()
 		if err != nil {
-			nakCallback(ErrEncryption)
+			nakCallback(handlerError(ctx, err))
 			return
 		}
 
-		if err := msg.RespondMsg(respMsg); err != nil {
-			nakCallback(ErrOther.With("failed to respond 'get'"))
+		if respErr := s.respondSealed(ctx, msg, hostPubKey, &BatchResponse{Results: results}); respErr != nil {
+			nakCallback(respErr)
 		}
 	case "server_xkey":
-		if err := msg.Respond([]byte(s.pubKey)); err != nil {
+		if err := msg.Respond([]byte(s.PublicKey())); err != nil {
 			nakCallback(ErrInvalidRequest)
 		}
 
@@ -203,27 +556,281 @@ func (s *Server) Process(ctx context.Context, msg *nats.Msg) {
 	}
 }
 
+// openRequest decrypts and decodes a sealed request into 'req', returning the host key to seal the response with.
+func (s *Server) openRequest(ctx context.Context, msg *nats.Msg, req sealedRequest) (string, *ResponseError) {
+	hostPubKey := msg.Header.Get(WasmCloudHostXkey)
+	if !nkeys.IsValidPublicCurveKey(hostPubKey) {
+		return "", ErrInvalidHeaders
+	}
+
+	_, decryptSpan := s.tracer.Start(ctx, "decrypt")
+	rawReq, err := s.open(msg.Data, hostPubKey)
+	decryptSpan.End()
+	if err != nil {
+		return "", ErrDecryption
+	}
+
+	if err := json.Unmarshal(rawReq, req); err != nil {
+		return "", ErrInvalidPayload
+	}
+
+	return hostPubKey, nil
+}
+
+// admitRequest validates an opened request, then applies replay protection and rate limits.
+// 'n' is the number of secrets requested, each counts against the rate limits.
+func (s *Server) admitRequest(ctx context.Context, hostPubKey string, msg *nats.Msg, req sealedRequest, n int) *ResponseError {
+	reqCtx, timestamp := req.envelope()
+
+	_, validateSpan := s.tracer.Start(ctx, "validate")
+	validationErr := reqCtx.Validate(s.validation)
+	validateSpan.End()
+	if validationErr != nil {
+		return validationErr
+	}
+
+	if err := s.checkReplay(ctx, hostPubKey, msg.Data, timestamp); err != nil {
+		return err
+	}
+
+	return s.checkRateLimits(*reqCtx, n)
+}
+
+// respondSealed encrypts 'resp' for the host with an ephemeral key.
+func (s *Server) respondSealed(ctx context.Context, msg *nats.Msg, hostPubKey string, resp interface{}) *ResponseError {
+	_, encryptSpan := s.tracer.Start(ctx, "encrypt")
+	defer encryptSpan.End()
+
+	responseKey, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		return ErrEncryption
+	}
+	ephemeralPubKey, err := responseKey.PublicKey()
+	if err != nil {
+		return ErrEncryption
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return ErrInvalidPayload
+	}
+
+	respMsg := nats.NewMsg("")
+	respMsg.Header.Add(WasmCloudResponseXkey, ephemeralPubKey)
+
+	respMsg.Data, err = responseKey.Seal(data, hostPubKey)
+	if err != nil {
+		return ErrEncryption
+	}
+
+	if err := msg.RespondMsg(respMsg); err != nil {
+		return ErrOther.With("failed to respond '" + s.operation(msg) + "'")
+	}
+
+	return nil
+}
+
+// respondError sends a plain text protocol error.
+func (s *Server) respondError(msg *nats.Msg, respErr *ResponseError) {
+	s.onError(msg, respErr)
+
+	resp := Response{Error: respErr}
+
+	data, err := json.Marshal(&resp)
+	if err != nil {
+		s.onError(msg, err)
+		return
+	}
+
+	if err := msg.Respond(data); err != nil {
+		s.onError(msg, err)
+	}
+}
+
+// reject answers a request that won't be processed.
+func (s *Server) reject(msg *nats.Msg, respErr *ResponseError) {
+	operation := s.operation(msg)
+
+	s.respondError(msg, respErr)
+	if operation != "server_xkey" && operation != "unknown" {
+		s.onAudit(msg, operation, nil, nil, respErr)
+	}
+	s.onRequest(msg, operation, respErr, 0)
+}
+
 func (s *Server) Run() error {
-	var queueErr error
+	callback := func(msg *nats.Msg) {
+		ctx, cancel := s.requestContext()
+		defer cancel()
+		s.Process(ctx, msg)
+	}
+
+	if s.pool != nil {
+		s.pool.Start(s.concurrency, s.processQueued)
+		callback = func(msg *nats.Msg) {
+			ctx, cancel := s.requestContext()
+			if !s.pool.Submit(ctx, msg, cancel) {
+				cancel()
+				s.reject(msg, ErrServerBusy)
+			}
+		}
+	}
 
-	s.queue, queueErr = s.natsConn.QueueSubscribe(
+	queue, err := s.natsConn.QueueSubscribe(
 		s.subjectMapper.SecretWildcardSubject(),
 		s.subjectMapper.QueueGroupName(),
-		func(msg *nats.Msg) {
-			s.Process(s.ctxCreator(), msg)
-		})
+		callback)
+	if err != nil {
+		if s.pool != nil {
+			s.pool.Stop(s.abandon)
+		}
+		return err
+	}
+
+	s.queue.Store(queue)
+	return nil
+}
+
+// abandon answers a queued request the server stopped before processing, so hosts can retry elsewhere.
+func (s *Server) abandon(msg *nats.Msg) {
+	s.reject(msg, ErrServerBusy)
+}
 
-	return queueErr
+func (s *Server) processQueued(ctx context.Context, msg *nats.Msg) {
+	if ctx.Err() != nil {
+		s.reject(msg, contextError(ctx))
+		return
+	}
+
+	s.Process(ctx, msg)
+}
+
+// requestContext creates the context of a newly received request, applying the request timeout.
+func (s *Server) requestContext() (context.Context, context.CancelFunc) {
+	ctx := s.ctxCreator()
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+type handlerResult[T any] struct {
+	value T
+	err   error
+}
+
+// handle calls the handler, giving up as soon as 'ctx' is done even if the handler doesn't honor it.
+// See callHandler for the returned wait function.
+func (s *Server) handle(ctx context.Context, req *Request) (*SecretValue, func(), error) {
+	return callHandler(ctx, func(ctx context.Context) (*SecretValue, error) {
+		return s.handler.Get(ctx, req)
+	})
+}
+
+// callHandler runs 'fn' in the background, returning early with a contextError once 'ctx' is done.
+// The returned function waits for 'fn' to return. Callers answer first, then wait, so handlers ignoring 'ctx'
+// keep holding their worker instead of piling up in the background.
+func callHandler[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (T, func(), error) {
+	done := make(chan handlerResult[T], 1)
+	go func() {
+		value, err := fn(ctx)
+		done <- handlerResult[T]{value: value, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, func() {}, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, func() { <-done }, contextError(ctx)
+	}
+}
+
+// handlerError maps an error returned by the handler to the error returned to the caller.
+func handlerError(ctx context.Context, err error) *ResponseError {
+	if ctx.Err() != nil {
+		return contextError(ctx)
+	}
+	if respErr, ok := err.(*ResponseError); ok {
+		return respErr
+	}
+	return ErrUpstream.With(err.Error())
+}
+
+// contextError maps a done context to the error returned to the caller.
+func contextError(ctx context.Context) *ResponseError {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ErrOther.With("request cancelled")
+}
+
+// QueueStats returns how many requests are waiting for a worker and the queue capacity.
+// Both are zero unless WithConcurrency is used.
+func (s *Server) QueueStats() (pending int, capacity int) {
+	if s.pool == nil {
+		return 0, 0
+	}
+
+	return s.pool.Pending(), s.pool.Capacity()
+}
+
+// Live reports whether the server can still recover on its own.
+// It fails once the NATS connection is permanently closed.
+func (s *Server) Live() error {
+	if s.natsConn.IsClosed() {
+		return ErrNotConnected
+	}
+
+	return nil
+}
+
+// Ready reports whether the server is connected to NATS and subscribed to requests.
+func (s *Server) Ready() error {
+	if !s.natsConn.IsConnected() {
+		return ErrNotConnected
+	}
+
+	if queue := s.queue.Load(); queue == nil || !queue.IsValid() {
+		return ErrNotSubscribed
+	}
+
+	return nil
 }
 
 func (s *Server) Shutdown(shouldDrain bool) error {
-	if s.queue != nil {
+	queue := s.queue.Load()
+	if queue == nil {
+		return nil
+	}
+
+	if s.pool == nil {
 		if shouldDrain {
-			return s.queue.Drain()
+			return queue.Drain()
 		} else {
-			return s.queue.Unsubscribe()
+			return queue.Unsubscribe()
 		}
 	}
 
+	// with a worker pool, wait for queued requests to be processed before stopping workers
+	defer s.pool.Stop(s.abandon)
+
+	if !shouldDrain {
+		return queue.Unsubscribe()
+	}
+
+	closed := queue.StatusChanged(nats.SubscriptionClosed)
+	if err := queue.Drain(); err != nil {
+		return err
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(s.natsConn.Opts.DrainTimeout):
+		return nats.ErrDrainTimeout
+	}
+
+	s.pool.Wait()
+
 	return nil
 }
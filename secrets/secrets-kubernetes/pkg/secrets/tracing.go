@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/wasmCloud/contrib/secrets/secrets-kubernetes/pkg/secrets"
+
+// natsHeaderCarrier adapts NATS message headers for trace context propagation.
+// Lookups are case-insensitive, as hosts may not canonicalize header names.
+type natsHeaderCarrier nats.Header
+
+var _ propagation.TextMapCarrier = natsHeaderCarrier{}
+
+func (c natsHeaderCarrier) Get(key string) string {
+	if v, ok := c[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+
+	for k, v := range c {
+		if strings.EqualFold(k, key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+
+	return ""
+}
+
+func (c natsHeaderCarrier) Set(key string, value string) {
+	c[key] = []string{value}
+}
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
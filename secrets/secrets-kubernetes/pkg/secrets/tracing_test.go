@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNatsHeaderCarrier(t *testing.T) {
+	header := nats.Header{}
+	header.Set("traceparent", "lowercase")
+	header.Set("Tracestate", "canonical")
+
+	carrier := natsHeaderCarrier(header)
+
+	if want, got := "lowercase", carrier.Get("traceparent"); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if want, got := "canonical", carrier.Get("tracestate"); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if want, got := "", carrier.Get("missing"); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	carrier.Set("baggage", "value")
+	if want, got := 3, len(carrier.Keys()); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if want, got := "", natsHeaderCarrier(nil).Get("traceparent"); want != got {
+		t.Errorf("nil headers: want %v, got %v", want, got)
+	}
+}
+
+func TestServerTracing(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	var handler testHandler
+
+	server, err := NewServer("kube", nc, &handler, WithEphemeralKey(), WithTracerProvider(tp), WithPropagator(propagation.TraceContext{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(false) })
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := nats.NewMsg(server.subjectMapper.SecretsSubject() + ".server_xkey")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	if _, err := nc.RequestMsg(req, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// spans end after the response is sent
+	deadline := time.Now().Add(time.Second)
+	for len(exporter.GetSpans()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	spans := exporter.GetSpans()
+	if want, got := 1, len(spans); want != got {
+		t.Fatalf("want %v spans, got %v", want, got)
+	}
+
+	if want, got := "secrets server_xkey", spans[0].Name; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if want, got := traceID, spans[0].SpanContext.TraceID().String(); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
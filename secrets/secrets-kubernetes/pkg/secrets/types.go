@@ -17,10 +17,15 @@ const (
 	DefaultSecretsProtocolVersion = "v1alpha1"
 	WasmCloudHostXkey             = "WasmCloud-Host-Xkey"
 	WasmCloudResponseXkey         = "Server-Response-Xkey"
+	DefaultQueueSize              = 128
+	DefaultMaxBatchSize           = 64
 )
 
 var (
 	ErrInvalidServerConfig = errors.New("invalid server configuration")
+	ErrInvalidClientConfig = errors.New("invalid client configuration")
+	ErrNotConnected        = errors.New("nats not connected")
+	ErrNotSubscribed       = errors.New("not subscribed")
 
 	ErrSecretNotFound = newResponseError("SecretNotFound", false)
 	ErrInvalidRequest = newResponseError("InvalidRequest", false)
@@ -34,12 +39,25 @@ var (
 	ErrUpstream         = newResponseError("UpstreamError", true)
 	ErrPolicy           = newResponseError("PolicyError", true)
 	ErrOther            = newResponseError("Other", true)
+
+	// Hosts only know the tips above, so the errors below reuse them with a fixed message and their own Kind.
+	// Match them with errors.Is, which compares messages as well.
+	ErrVersionNotFound = ErrOther.withKind("VersionNotFound", "version not found")
+	ErrServerBusy      = ErrOther.withKind("ServerBusy", "server busy")
+	ErrTimeout         = ErrOther.withKind("Timeout", "timeout")
+	ErrRateLimited     = ErrOther.withKind("RateLimited", "rate limited")
+	ErrReplay          = ErrPolicy.withKind("Replay", "request already processed")
+
+	kindedErrors = []*ResponseError{ErrVersionNotFound, ErrServerBusy, ErrTimeout, ErrRateLimited, ErrReplay}
 )
 
 type ResponseError struct {
 	Tip        string
 	HasMessage bool
 	Message    string
+	// Kind names the error for metrics and spans, from a fixed set. It is the tip, or a more specific name
+	// for errors sharing a tip, like ErrServerBusy. It isn't sent to hosts.
+	Kind string `json:"-"`
 }
 
 func (re ResponseError) With(msg string) *ResponseError {
@@ -48,28 +66,52 @@ func (re ResponseError) With(msg string) *ResponseError {
 	return &otherError
 }
 
+func (re ResponseError) withKind(kind string, msg string) *ResponseError {
+	kinded := re.With(msg)
+	kinded.Kind = kind
+	return kinded
+}
+
 func (re ResponseError) Error() string {
 	return re.Tip
 }
 
+// Is reports whether 'target' is a ResponseError with the same tip, and the same message unless the target has none.
+// errors.Is(err, ErrOther) matches every Other error, errors.Is(err, ErrServerBusy) only busy servers.
+func (re ResponseError) Is(target error) bool {
+	t, ok := target.(*ResponseError)
+	if !ok || t == nil {
+		return false
+	}
+	return re.Tip == t.Tip && (t.Message == "" || re.Message == t.Message)
+}
+
 func (re *ResponseError) UnmarshalJSON(data []byte) error {
 	serdeSpecial := make(map[string]string)
 	if err := json.Unmarshal(data, &serdeSpecial); err != nil {
-		var msg string
-		if err := json.Unmarshal(data, &msg); err != nil {
+		// errors without a message are serialized as their bare tip
+		var tip string
+		if err := json.Unmarshal(data, &tip); err != nil {
 			return err
 		}
-		*re = *ErrOther.With(msg)
+		*re = ResponseError{Tip: tip, Kind: tip}
 		return nil
 	}
 	if len(serdeSpecial) != 1 {
 		return errors.New("couldn't parse ResponseError")
 	}
 	for k, v := range serdeSpecial {
-		*re = ResponseError{Tip: k, HasMessage: v != "", Message: v}
+		*re = ResponseError{Tip: k, HasMessage: v != "", Message: v, Kind: k}
 		break
 	}
 
+	for _, kinded := range kindedErrors {
+		if re.Is(kinded) {
+			re.Kind = kinded.Kind
+			break
+		}
+	}
+
 	return nil
 }
 
@@ -89,7 +131,7 @@ func (re *ResponseError) MarshalJSON() ([]byte, error) {
 }
 
 func newResponseError(tip string, hasMessage bool) *ResponseError {
-	return &ResponseError{Tip: tip, HasMessage: hasMessage}
+	return &ResponseError{Tip: tip, HasMessage: hasMessage, Kind: tip}
 }
 
 // SubjectMapper helps manipulating NATS subjects
@@ -144,24 +186,41 @@ type Context struct {
 	EntityJwt string `json:"entity_jwt"`
 	/// The host's signed JWT.
 	HostJwt string `json:"host_jwt"`
+
+	// set by Validate, shared by copies of the validated Context
+	verified *verifiedClaims
 }
 
-func (ctx Context) IsValid() *ResponseError {
-	if _, _, err := ctx.EntityCapabilities(); err != nil {
-		return err
-	}
+// verifiedClaims are the entity and host claims checked by Validate.
+type verifiedClaims struct {
+	entityCap    *WasCap
+	entityClaims *ComponentClaims
+	hostCap      *WasCap
+	hostClaims   *HostClaims
+}
 
-	if _, _, err := ctx.HostCapabilities(); err != nil {
-		return err
-	}
+func (ctx Context) IsValid() *ResponseError {
+	return ctx.Validate(ValidationOptions{})
+}
 
-	return nil
+// Verified reports whether the Context passed Validate, so its capabilities come from verified JWTs.
+func (ctx Context) Verified() bool {
+	return ctx.verified != nil
 }
 
+// EntityCapabilities returns the claims verified by Validate.
+// Contexts that weren't validated have their entity JWT parsed and checked against its own issuer, with no clock skew.
 func (ctx Context) EntityCapabilities() (*WasCap, *ComponentClaims, *ResponseError) {
-	token, err := jwt.ParseWithClaims(ctx.EntityJwt, &WasCap{}, KeyPairFromIssuer())
+	if ctx.verified != nil {
+		return ctx.verified.entityCap, ctx.verified.entityClaims, nil
+	}
+	return ctx.entityCapabilities(KeyPairFromIssuer())
+}
+
+func (ctx Context) entityCapabilities(keyFunc jwt.Keyfunc, parserOpts ...jwt.ParserOption) (*WasCap, *ComponentClaims, *ResponseError) {
+	token, err := jwt.ParseWithClaims(ctx.EntityJwt, &WasCap{}, keyFunc, parserOpts...)
 	if err != nil {
-		return nil, nil, ErrInvalidEntityJWT.With(err.Error())
+		return nil, nil, ErrInvalidEntityJWT.With(jwtErrorMessage(err))
 	}
 
 	wasCap, ok := token.Claims.(*WasCap)
@@ -177,10 +236,19 @@ func (ctx Context) EntityCapabilities() (*WasCap, *ComponentClaims, *ResponseErr
 	return wasCap, compCap, nil
 }
 
+// HostCapabilities returns the claims verified by Validate.
+// Contexts that weren't validated have their host JWT parsed and checked against its own issuer, with no clock skew.
 func (ctx Context) HostCapabilities() (*WasCap, *HostClaims, *ResponseError) {
-	token, err := jwt.ParseWithClaims(ctx.HostJwt, &WasCap{}, KeyPairFromIssuer())
+	if ctx.verified != nil {
+		return ctx.verified.hostCap, ctx.verified.hostClaims, nil
+	}
+	return ctx.hostCapabilities(KeyPairFromIssuer())
+}
+
+func (ctx Context) hostCapabilities(keyFunc jwt.Keyfunc, parserOpts ...jwt.ParserOption) (*WasCap, *HostClaims, *ResponseError) {
+	token, err := jwt.ParseWithClaims(ctx.HostJwt, &WasCap{}, keyFunc, parserOpts...)
 	if err != nil {
-		return nil, nil, ErrInvalidHostJWT.With(err.Error())
+		return nil, nil, ErrInvalidHostJWT.With(jwtErrorMessage(err))
 	}
 
 	wasCap, ok := token.Claims.(*WasCap)
@@ -201,6 +269,12 @@ type Request struct {
 	Field   string  `json:"field"`
 	Version string  `json:"version"`
 	Context Context `json:"context"`
+	// Timestamp is when the request was sealed, in seconds since the epoch. Optional, see WithRequireRequestTimestamp.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+func (s *Request) envelope() (*Context, int64) {
+	return &s.Context, s.Timestamp
 }
 
 func (s Request) Write(w io.Writer) error {
@@ -215,6 +289,11 @@ func (s Request) String() string {
 	return b.String()
 }
 
+// sealedRequest is a payload sealed by a host, carrying the context and timestamp checked before it is handled.
+type sealedRequest interface {
+	envelope() (*Context, int64)
+}
+
 type ByteArray []uint8
 
 func (u ByteArray) MarshalJSON() ([]byte, error) {
@@ -227,6 +306,29 @@ func (u ByteArray) MarshalJSON() ([]byte, error) {
 	return []byte(result), nil
 }
 
+func (u *ByteArray) UnmarshalJSON(data []byte) error {
+	var values []int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	if values == nil {
+		*u = nil
+		return nil
+	}
+
+	result := make(ByteArray, len(values))
+	for i, v := range values {
+		if v < 0 || v > 255 {
+			return fmt.Errorf("byte array value out of range: %d", v)
+		}
+		result[i] = uint8(v)
+	}
+
+	*u = result
+	return nil
+}
+
 type SecretValue struct {
 	Version      string    `json:"version,omitempty"`
 	StringSecret string    `json:"string_secret,omitempty"`
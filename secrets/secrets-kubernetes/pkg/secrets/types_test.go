@@ -1,6 +1,9 @@
 package secrets
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -96,3 +99,146 @@ func TestJWTClaims(t *testing.T) {
 		}
 	})
 }
+
+func TestByteArray(t *testing.T) {
+	tests := map[string]struct {
+		value ByteArray
+		json  string
+	}{
+		"empty":   {value: ByteArray{}, json: "[]"},
+		"single":  {value: ByteArray{42}, json: "[42]"},
+		"binary":  {value: ByteArray{0x30, 0x82, 0x00, 0xff}, json: "[48,130,0,255]"},
+		"invalid": {value: ByteArray{0xc3, 0x28}, json: "[195,40]"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want, got := test.json, string(data); want != got {
+				t.Errorf("Marshal: want %v, got %v", want, got)
+			}
+
+			var decoded ByteArray
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(test.value, decoded) {
+				t.Errorf("Unmarshal: want %v, got %v", test.value, decoded)
+			}
+		})
+	}
+
+	t.Run("OutOfRange", func(t *testing.T) {
+		var decoded ByteArray
+		if err := json.Unmarshal([]byte("[1,256]"), &decoded); err == nil {
+			t.Error("expected out of range error")
+		}
+	})
+
+	t.Run("SecretValue", func(t *testing.T) {
+		value := SecretValue{
+			Version:      "1",
+			BinarySecret: ByteArray{0xde, 0xad, 0xbe, 0xef},
+		}
+
+		data, err := json.Marshal(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, got := `{"version":"1","binary_secret":[222,173,190,239]}`, string(data); want != got {
+			t.Errorf("Marshal: want %v, got %v", want, got)
+		}
+
+		var decoded SecretValue
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.StringSecret != "" {
+			t.Errorf("unexpected string secret %q", decoded.StringSecret)
+		}
+
+		if !bytes.Equal(value.BinarySecret, decoded.BinarySecret) {
+			t.Errorf("Unmarshal: want %v, got %v", value.BinarySecret, decoded.BinarySecret)
+		}
+	})
+}
+
+func TestResponseError(t *testing.T) {
+	tests := map[string]struct {
+		err  *ResponseError
+		json string
+	}{
+		"tip":     {err: ErrSecretNotFound, json: `"SecretNotFound"`},
+		"message": {err: ErrUpstream.With("boom"), json: `{"UpstreamError":"boom"}`},
+		"busy":    {err: ErrServerBusy, json: `{"Other":"server busy"}`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(test.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want, got := test.json, string(data); want != got {
+				t.Errorf("Marshal: want %v, got %v", want, got)
+			}
+
+			var decoded ResponseError
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			if want, got := *test.err, decoded; want != got {
+				t.Errorf("Unmarshal: want %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestResponseErrorIs(t *testing.T) {
+	if !errors.Is(ErrOther.With("server busy"), ErrServerBusy) {
+		t.Error("errors with the same tip and message should match")
+	}
+	if !errors.Is(ErrServerBusy, ErrOther) {
+		t.Error("targets without a message should match every message")
+	}
+	if errors.Is(ErrOther.With("boom"), ErrServerBusy) {
+		t.Error("errors with another message shouldn't match")
+	}
+	if errors.Is(ErrPolicy.With("server busy"), ErrServerBusy) {
+		t.Error("errors with another tip shouldn't match")
+	}
+}
+
+func TestResponseErrorKind(t *testing.T) {
+	if want, got := "SecretNotFound", ErrSecretNotFound.Kind; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want, got := "Other", ErrOther.With("boom").Kind; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// kinds aren't sent to hosts, but are recovered from known messages
+	for _, sent := range []*ResponseError{ErrServerBusy, ErrReplay, ErrOther.With("boom")} {
+		data, err := json.Marshal(sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		received := &ResponseError{}
+		if err := json.Unmarshal(data, received); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := sent.Kind, received.Kind; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"errors"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nkeys"
+)
+
+// ValidationOptions controls how the entity and host JWTs in a request Context are verified.
+type ValidationOptions struct {
+	// Account/operator public keys allowed to issue entity JWTs. Empty trusts any issuer.
+	TrustedEntityIssuers []string
+	// Account/operator/cluster public keys allowed to issue host JWTs. Empty trusts any issuer.
+	TrustedHostIssuers []string
+	// Tolerated clock difference when checking 'exp', 'nbf' and 'iat'.
+	ClockSkew time.Duration
+	// Reject tokens without an 'exp' claim.
+	RequireExpiration bool
+}
+
+func (opts ValidationOptions) parserOptions() []jwt.ParserOption {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithLeeway(opts.ClockSkew),
+		jwt.WithIssuedAt(),
+	}
+	if opts.RequireExpiration {
+		parserOpts = append(parserOpts, jwt.WithExpirationRequired())
+	}
+	return parserOpts
+}
+
+type (
+	entityCheck func(wasCap *WasCap, claims *ComponentClaims) *ResponseError
+	hostCheck   func(wasCap *WasCap, claims *HostClaims) *ResponseError
+)
+
+var (
+	entityChecks = []entityCheck{checkEntitySubject, checkModuleHash}
+	hostChecks   = []hostCheck{checkHostSubject}
+)
+
+// Validate verifies the entity and host JWTs: signatures, issuers, time based claims and subject key types.
+// The WasmCloud-Host-Xkey header isn't checked against the host JWT: hosts generate their xkey apart from
+// their server and cluster keys, and no claim carries it, so a sealed request can't be tied to a host identity.
+// Once validated, EntityCapabilities and HostCapabilities return the verified claims instead of parsing the JWTs again,
+// so authorization and rate limits see what was checked here, with the same clock skew and trusted issuers.
+func (ctx *Context) Validate(opts ValidationOptions) *ResponseError {
+	ctx.verified = nil
+	parserOpts := opts.parserOptions()
+
+	entityCap, entityClaims, err := ctx.entityCapabilities(KeyPairFromTrustedIssuer(opts.TrustedEntityIssuers), parserOpts...)
+	if err != nil {
+		return err
+	}
+
+	for _, check := range entityChecks {
+		if err := check(entityCap, entityClaims); err != nil {
+			return err
+		}
+	}
+
+	hostCap, hostClaims, err := ctx.hostCapabilities(KeyPairFromTrustedIssuer(opts.TrustedHostIssuers), parserOpts...)
+	if err != nil {
+		return err
+	}
+
+	for _, check := range hostChecks {
+		if err := check(hostCap, hostClaims); err != nil {
+			return err
+		}
+	}
+
+	ctx.verified = &verifiedClaims{
+		entityCap:    entityCap,
+		entityClaims: entityClaims,
+		hostCap:      hostCap,
+		hostClaims:   hostClaims,
+	}
+	return nil
+}
+
+// checkEntitySubject ensures entities are identified by module ('M') or provider/service ('V') keys.
+func checkEntitySubject(wasCap *WasCap, _ *ComponentClaims) *ResponseError {
+	sub := wasCap.Subject
+	if sub == "" || (sub[0] != 'M' && sub[0] != 'V') || !nkeys.IsValidEncoding([]byte(sub)) {
+		return ErrInvalidEntityJWT.With("subject is not a module or provider key")
+	}
+	return nil
+}
+
+// checkModuleHash ensures component claims carry the hash of the signed module.
+func checkModuleHash(wasCap *WasCap, claims *ComponentClaims) *ResponseError {
+	if wasCap.Subject[0] == 'M' && claims.ModuleHash == "" {
+		return ErrInvalidEntityJWT.With("missing module hash")
+	}
+	return nil
+}
+
+// checkHostSubject ensures hosts are identified by server ('N') keys.
+func checkHostSubject(wasCap *WasCap, _ *HostClaims) *ResponseError {
+	if !nkeys.IsValidPublicServerKey(wasCap.Subject) {
+		return ErrInvalidHostJWT.With("subject is not a server key")
+	}
+	return nil
+}
+
+func jwtErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return "token not valid yet"
+	case errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "token used before issued"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "token missing expiration"
+	case errors.Is(err, ErrUntrustedIssuer):
+		return "untrusted issuer"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "invalid signature"
+	default:
+		return err.Error()
+	}
+}
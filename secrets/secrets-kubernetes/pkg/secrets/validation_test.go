@@ -0,0 +1,297 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/nats-io/nkeys"
+)
+
+// module key from the wasmcloud host codebase, nkeys can't generate 'M' keys
+const testModuleKey = "MC5CC4UD5LPDZ4C7ZNAEA4OZQ3BEFLSVQ742W3TET3ONKS4DRBVNM5IC"
+
+func serverKeyForTest(t *testing.T) string {
+	t.Helper()
+
+	kp, err := nkeys.CreateServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, err := kp.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pubKey
+}
+
+func TestValidate(t *testing.T) {
+	entityIssuer := accountKeyForTest(t)
+	entityIssuerPubKey, err := entityIssuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostIssuer := accountKeyForTest(t)
+	hostIssuerPubKey, err := hostIssuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherIssuer := accountKeyForTest(t)
+	otherIssuerPubKey, err := otherIssuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusterIssuer, err := nkeys.CreateCluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterIssuerPubKey, err := clusterIssuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKey := serverKeyForTest(t)
+	componentClaims := &ComponentClaims{Name: "component", ModuleHash: "CE90192C99C0B2C608B2E2CB619A9251FB681856C15681B1BCD62EEDA2D5128E"}
+	hostClaims := &HostClaims{Name: "host"}
+
+	entityJWT := func(registered jwt.RegisteredClaims, claims *ComponentClaims) string {
+		if registered.Subject == "" {
+			registered.Subject = testModuleKey
+		}
+		return signedWasCapForTest(t, entityIssuer, registered, claims)
+	}
+
+	hostJWT := func(registered jwt.RegisteredClaims) string {
+		if registered.Subject == "" {
+			registered.Subject = hostKey
+		}
+		return signedWasCapForTest(t, hostIssuer, registered, hostClaims)
+	}
+
+	validCtx := Context{
+		EntityJwt: entityJWT(jwt.RegisteredClaims{}, componentClaims),
+		HostJwt:   hostJWT(jwt.RegisteredClaims{}),
+	}
+
+	now := time.Now()
+
+	tests := map[string]struct {
+		ctx     Context
+		opts    ValidationOptions
+		err     *ResponseError
+		message string
+	}{
+		"trustAny": {ctx: validCtx},
+		"trusted": {
+			ctx: validCtx,
+			opts: ValidationOptions{
+				TrustedEntityIssuers: []string{otherIssuerPubKey, entityIssuerPubKey},
+				TrustedHostIssuers:   []string{hostIssuerPubKey},
+			},
+		},
+		"clusterHostIssuer": {
+			ctx: Context{
+				EntityJwt: validCtx.EntityJwt,
+				HostJwt:   signedWasCapForTest(t, clusterIssuer, jwt.RegisteredClaims{Subject: hostKey}, hostClaims),
+			},
+			opts: ValidationOptions{TrustedHostIssuers: []string{clusterIssuerPubKey}},
+		},
+		"untrustedEntity": {
+			ctx:     validCtx,
+			opts:    ValidationOptions{TrustedEntityIssuers: []string{otherIssuerPubKey}},
+			err:     ErrInvalidEntityJWT,
+			message: "untrusted issuer",
+		},
+		"untrustedHost": {
+			ctx:     validCtx,
+			opts:    ValidationOptions{TrustedHostIssuers: []string{otherIssuerPubKey}},
+			err:     ErrInvalidHostJWT,
+			message: "untrusted issuer",
+		},
+		"swappedIssuers": {
+			ctx: validCtx,
+			opts: ValidationOptions{
+				TrustedEntityIssuers: []string{hostIssuerPubKey},
+				TrustedHostIssuers:   []string{entityIssuerPubKey},
+			},
+			err: ErrInvalidEntityJWT,
+		},
+		"providerSubject": {
+			ctx: Context{
+				EntityJwt: entityJWT(jwt.RegisteredClaims{Subject: "VAAQEAYEAUDAOCAJBIFQYDIOB4IBCEQTCQKRMFYYDENBWHA5DYPSBK3B"}, &ComponentClaims{Name: "provider"}),
+				HostJwt:   validCtx.HostJwt,
+			},
+		},
+		"entitySubject": {
+			ctx: Context{
+				EntityJwt: entityJWT(jwt.RegisteredClaims{Subject: hostKey}, componentClaims),
+				HostJwt:   validCtx.HostJwt,
+			},
+			err:     ErrInvalidEntityJWT,
+			message: "subject is not a module or provider key",
+		},
+		"moduleHash": {
+			ctx: Context{
+				EntityJwt: entityJWT(jwt.RegisteredClaims{}, &ComponentClaims{Name: "component"}),
+				HostJwt:   validCtx.HostJwt,
+			},
+			err:     ErrInvalidEntityJWT,
+			message: "missing module hash",
+		},
+		"hostSubject": {
+			ctx: Context{
+				EntityJwt: validCtx.EntityJwt,
+				HostJwt:   hostJWT(jwt.RegisteredClaims{Subject: testModuleKey}),
+			},
+			err:     ErrInvalidHostJWT,
+			message: "subject is not a server key",
+		},
+		"expired": {
+			ctx: Context{
+				EntityJwt: entityJWT(jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(-time.Minute))}, componentClaims),
+				HostJwt:   validCtx.HostJwt,
+			},
+			err:     ErrInvalidEntityJWT,
+			message: "token expired",
+		},
+		"expiredWithinSkew": {
+			ctx: Context{
+				EntityJwt: entityJWT(jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(-time.Minute))}, componentClaims),
+				HostJwt:   validCtx.HostJwt,
+			},
+			opts: ValidationOptions{ClockSkew: 5 * time.Minute},
+		},
+		"notBefore": {
+			ctx: Context{
+				EntityJwt: validCtx.EntityJwt,
+				HostJwt:   hostJWT(jwt.RegisteredClaims{NotBefore: jwt.NewNumericDate(now.Add(time.Hour))}),
+			},
+			err:     ErrInvalidHostJWT,
+			message: "token not valid yet",
+		},
+		"notBeforeWithinSkew": {
+			ctx: Context{
+				EntityJwt: validCtx.EntityJwt,
+				HostJwt:   hostJWT(jwt.RegisteredClaims{NotBefore: jwt.NewNumericDate(now.Add(time.Minute))}),
+			},
+			opts: ValidationOptions{ClockSkew: 5 * time.Minute},
+		},
+		"requireExpiration": {
+			ctx:     validCtx,
+			opts:    ValidationOptions{RequireExpiration: true},
+			err:     ErrInvalidEntityJWT,
+			message: "token missing expiration",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.ctx.Validate(test.opts)
+			if test.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v (%s)", err, err.Message)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected an error but got none")
+			}
+			if want, got := test.err.Error(), err.Error(); want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if test.message != "" {
+				if want, got := test.message, err.Message; want != got {
+					t.Errorf("want %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServerClockSkew(t *testing.T) {
+	nc := natsConnectionForTest(t)
+
+	hostIssuer := accountKeyForTest(t)
+	expiredHostJWT := signedWasCapForTest(t, hostIssuer, jwt.RegisteredClaims{
+		Subject:   serverKeyForTest(t),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-10 * time.Second)),
+	}, &HostClaims{Name: "host", Labels: map[string]string{"env": "prod"}})
+
+	handler := &testHandler{
+		getFunc: func(_ context.Context, r *Request) (*SecretValue, error) {
+			if err := r.Context.AuthorizeHost(HostRule{Labels: map[string]string{"env": "prod"}}); err != nil {
+				return nil, err
+			}
+			if err := r.Context.AuthorizeEntity(EntityRule{}); err != nil {
+				return nil, err
+			}
+			return &SecretValue{StringSecret: "value"}, nil
+		},
+	}
+
+	tests := map[string]struct {
+		skew time.Duration
+		err  *ResponseError
+	}{
+		"withinSkew": {
+			skew: 30 * time.Second,
+		},
+		"noSkew": {
+			err: ErrInvalidHostJWT,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, err := NewServer("kube", nc, handler, WithEphemeralKey(), WithClockSkew(test.skew),
+				WithHostRateLimit(RateLimit{Rate: 10}), WithEntityRateLimit(RateLimit{Rate: 10}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.Run(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { server.Shutdown(false) })
+
+			reqCtx := contextForTest()
+			reqCtx.HostJwt = expiredHostJWT
+
+			kp := keyPairForTest(t)
+			reply, err := nc.RequestMsg(getRequestForTest(t, server, kp, &Request{Key: "secret", Context: reqCtx}), time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.err != nil {
+				if want, got := test.err.Tip, protocolErrorForTest(t, reply); want != got {
+					t.Errorf("want %v, got %v", want, got)
+				}
+				return
+			}
+
+			rawResponse, err := kp.Open(reply.Data, reply.Header.Get(WasmCloudResponseXkey))
+			if err != nil {
+				t.Fatalf("expected a sealed response: %v", err)
+			}
+			var resp Response
+			if err := json.Unmarshal(rawResponse, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Error != nil {
+				t.Fatalf("unexpected error: %v (%s)", resp.Error, resp.Error.Message)
+			}
+			if want, got := "value", resp.Secret.StringSecret; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const tracerName = "github.com/wasmCloud/contrib/secrets/secrets-kubernetes"
+
+var tracer = otel.Tracer(tracerName)
+
+// setupTracing installs a global OTLP/HTTP trace exporter.
+// The exporter honors the standard OTEL_EXPORTER_OTLP_* environment variables, 'endpoint' overrides them when set.
+func setupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	var exporterOpts []otlptracehttp.Option
+	if endpoint != "" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// detectors applied last win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "secrets-kubernetes")),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}